@@ -0,0 +1,71 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BoolWords is a vocabulary of cells meaning true and false.  Cells are matched ignoring case and surrounding space
+type BoolWords struct {
+	True  []string
+	False []string
+}
+
+// the vocabularies of the bools: sub-tag, by name
+var (
+	EnglishBools = BoolWords{True: []string{"true", "yes", "y", "t"}, False: []string{"false", "no", "n", "f"}}
+	GermanBools  = BoolWords{True: []string{"wahr", "ja", "j"}, False: []string{"falsch", "nein", "n"}}
+	FrenchBools  = BoolWords{True: []string{"vrai", "oui", "o"}, False: []string{"faux", "non", "n"}}
+	SpanishBools = BoolWords{True: []string{"verdadero", "sí", "si", "s"}, False: []string{"falso", "no", "n"}}
+	NumericBools = BoolWords{True: []string{"1"}, False: []string{"0"}}
+	CheckBools   = BoolWords{True: []string{"x"}, False: []string{""}} // a cross for true, blank for false
+
+	boolVocabularies = map[string]BoolWords{
+		"en": EnglishBools, "de": GermanBools, "fr": FrenchBools, "es": SpanishBools, "01": NumericBools, "x": CheckBools,
+	}
+)
+
+// mappedBools reads the values of value maps, which are always true or false
+var mappedBools = []BoolWords{{True: []string{"true"}, False: []string{"false"}}}
+
+// defaultBools is used when neither the params nor the field give a vocabulary
+var defaultBools = []BoolWords{EnglishBools, NumericBools}
+
+// parseBools reads the part of a bools: sub-tag after the colon, a ; separated list of vocabulary names
+func parseBools(spec string) ([]BoolWords, error) {
+	var vocabularies []BoolWords
+	for _, name := range strings.Split(spec, ";") {
+		words, ok := boolVocabularies[strings.ToLower(name)]
+		if !ok {
+			return nil, errors.New("bools must be a ; separated list of en, de, fr, es, 01 or x, not " + spec)
+		}
+		vocabularies = append(vocabularies, words)
+	}
+	return vocabularies, nil
+}
+
+// convertBool looks the cell up in the vocabularies of the params.  Cells in neither set are false,
+// or an error with StrictBools
+func convertBool(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+	vocabularies := params.Bools
+	if vocabularies == nil {
+		vocabularies = defaultBools
+	}
+	cell := strings.ToLower(strings.TrimSpace(input))
+	for _, words := range vocabularies {
+		if _, ok := find(words.True, cell); ok {
+			return reflect.ValueOf(true).Convert(outType), nil
+		}
+	}
+	if !params.StrictBools {
+		return reflect.ValueOf(false).Convert(outType), nil
+	}
+	for _, words := range vocabularies {
+		if _, ok := find(words.False, cell); ok {
+			return reflect.ValueOf(false).Convert(outType), nil
+		}
+	}
+	return reflect.Zero(outType), fmt.Errorf("stringToType could not convert %q to a bool", input)
+}
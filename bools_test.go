@@ -0,0 +1,73 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertBool(t *testing.T) {
+	german := []BoolWords{GermanBools, NumericBools}
+	tests := []struct {
+		input  string
+		params Params
+		want   interface{} // nil if the input should not convert
+	}{
+		{"true", Params{}, true},
+		{" Yes ", Params{}, true},
+		{"T", Params{}, true},
+		{"1", Params{}, true},
+		{"no", Params{}, false},
+		{"0", Params{}, false},
+		{"maybe", Params{}, false},
+		{"", Params{}, false},
+		{"ja", Params{}, false},
+		{"Ja", Params{Bools: german}, true},
+		{"nein", Params{Bools: german}, false},
+		{"yes", Params{Bools: german}, false},
+		{"SÍ", Params{Bools: []BoolWords{SpanishBools}}, true},
+		{"oui", Params{Bools: []BoolWords{FrenchBools}}, true},
+		{"x", Params{Bools: []BoolWords{CheckBools}}, true},
+		{"", Params{Bools: []BoolWords{CheckBools}, StrictBools: true}, false},
+		{"no", Params{StrictBools: true}, false},
+		{"maybe", Params{StrictBools: true}, nil},
+		{"", Params{StrictBools: true}, nil},
+		{"yes", Params{Bools: german, StrictBools: true}, nil},
+		{"aye", Params{Bools: []BoolWords{{True: []string{"aye"}, False: []string{"nay"}}}, StrictBools: true}, true},
+		{"NAY", Params{Bools: []BoolWords{{True: []string{"aye"}, False: []string{"nay"}}}, StrictBools: true}, false},
+	}
+	for _, tt := range tests {
+		value, err := ConvertString(tt.input, reflect.TypeOf(false), tt.params)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q with %+v gave %v, want an error", tt.input, tt.params, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q with %+v: %v", tt.input, tt.params, err)
+		} else if value.Interface() != tt.want {
+			t.Errorf("%q with %+v gave %v, want %v", tt.input, tt.params, value, tt.want)
+		}
+	}
+}
+
+type testOrganic struct {
+	Name    string `xtg:"col:Name"`
+	Organic bool   `xtg:"col:Bio,bools:de;01"`
+	Checked bool   `xtg:"col:Checked,bools:x"`
+}
+
+// the bools: sub-tag gives a field its own vocabularies, overriding those of the params
+func TestBoolsTag(t *testing.T) {
+	content := "Name;Bio;Checked\nCox;Ja;x\nGala;0;\nFuji;yes;X\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testOrganic{}, Params{Bools: []BoolWords{EnglishBools}, StrictBools: true})
+	want := []testOrganic{{"Cox", true, true}, {"Gala", false, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	checkErrs(t, err, []string{"row 4: field Organic", `"yes"`})
+
+	if _, err := parseBools("de;klingon"); err == nil {
+		t.Error("bools:de;klingon did not fail")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/c4rnot/csv_to_gorm"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func importCmd(args []string) {
+	var cf csvFlags
+	var mappingFile, driver, dsn, table, mode, keys, scope, duplicates string
+	var batchSize, workers int
+	var migrate, gormModel, progress, logImport, force bool
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&mappingFile, "mapping", "", "mapping file (YAML or JSON) describing the fields. Every column is imported with an inferred type if not given")
+	fs.StringVar(&driver, "driver", "postgres", "database driver: postgres or sqlite")
+	fs.StringVar(&dsn, "dsn", os.Getenv("DATABASE_URL"), "data source name. For sqlite this is the database file. Defaults to $DATABASE_URL")
+	fs.StringVar(&table, "table", "", "table to import into. Defaults to the file name")
+	fs.IntVar(&batchSize, "batch", 1000, "number of records per insert")
+	fs.IntVar(&workers, "workers", 1, "number of goroutines converting rows")
+	fs.BoolVar(&migrate, "migrate", true, "create or alter the table to fit the records")
+	fs.BoolVar(&gormModel, "gorm", true, "give records read with a mapping file the ID, CreatedAt, UpdatedAt and DeletedAt fields of gorm.Model")
+	fs.StringVar(&mode, "mode", "insert", "what to do with rows already in the table: insert, upsert, update (only), skip or replace (the rows in -scope)")
+	fs.StringVar(&keys, "keys", "", "comma separated columns identifying a row, for modes other than insert. Taken from the mapping file if not given")
+	fs.StringVar(&scope, "scope", "", "SQL condition picking out the rows a replace import deletes. Taken from the constants of the mapping file if not given")
+	fs.BoolVar(&logImport, "log", false, "record the import, with the hash of the file and the outcome, in the import_logs table")
+	fs.StringVar(&duplicates, "duplicates", "import", "what to do with a file already imported into the table: import (again), skip or fail. Checked against the import_logs table")
+	fs.BoolVar(&force, "force", false, "import even if -duplicates finds the file was imported before")
+	fs.BoolVar(&progress, "progress", false, "show how far through the file the import has got")
+	fileName := parseArgs(fs, args)
+
+	if table == "" {
+		table = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+
+	file, sep := cf.openCsv(fileName)
+	defer file.Close()
+
+	db, err := openDb(driver, dsn)
+	if err != nil {
+		log.Fatal("failed to connect database: ", err)
+	}
+
+	params := csv_to_gorm.ImportParams{
+		Params:    cf.params(),
+		BatchSize: batchSize,
+		Migrate:   migrate,
+		LogImport: logImport,
+		Force:     force,
+	}
+
+	params.Workers = workers
+	params.Mode, err = csv_to_gorm.ParseImportMode(mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch duplicates {
+	case "import":
+	case "skip":
+		params.OnDuplicate = csv_to_gorm.SkipDuplicates
+	case "fail":
+		params.OnDuplicate = csv_to_gorm.FailDuplicates
+	default:
+		log.Fatal("-duplicates should be import, skip or fail, not ", duplicates)
+	}
+	if keys != "" {
+		params.Keys = strings.Split(keys, ",")
+	}
+	if scope != "" {
+		params.Scope = func(db *gorm.DB) *gorm.DB {
+			return db.Where(scope)
+		}
+	}
+	if progress {
+		params.Progress = printProgress
+	}
+
+	// Ctrl-C stops the import between batches rather than part way through one
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if mappingFile == "" {
+		// no mapping, so every column goes into the table with the type it appears to have
+		result, err := csv_to_gorm.ImportTableContext(ctx, db, table, file, sep, params)
+		printResult(result, table)
+		if err != nil {
+			log.Fatal("could not import records: ", err)
+		}
+		return
+	}
+
+	m, err := csv_to_gorm.LoadMapping(mappingFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	modelTyp, err := mappedModelType(m, gormModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params.Mapping = m
+
+	result, err := csv_to_gorm.ImportContext(ctx, db.Table(table), file, sep, reflect.New(modelTyp).Interface(), params)
+	printResult(result, table)
+	if err != nil {
+		log.Fatal("could not import all records: ", err)
+	}
+}
+
+func printResult(result csv_to_gorm.ImportResult, table string) {
+	if result.DuplicateOf != "" {
+		fmt.Printf("%s: skipped, as the file was already imported by %s\n", table, result.DuplicateOf)
+		return
+	}
+	fmt.Printf("%s: %d deleted, %d inserted, %d updated, %d skipped, %d filtered, %d rows rejected\n",
+		table, result.Deleted, result.Inserted, result.Updated, result.Skipped, result.Filtered, result.Rejected)
+}
+
+func openDb(driver, dsn string) (*gorm.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("no dsn given")
+	}
+	var dialector gorm.Dialector
+	switch driver {
+	case "postgres":
+		dialector = postgres.Open(dsn)
+	case "sqlite":
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unknown driver %s", driver)
+	}
+	return gorm.Open(dialector, &gorm.Config{})
+}
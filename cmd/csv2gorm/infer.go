@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/c4rnot/csv_to_gorm"
+)
+
+func inferCmd(args []string) {
+	var cf csvFlags
+	var typeName string
+	var gormModel bool
+	fs := flag.NewFlagSet("infer", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&typeName, "type", "", "name of the generated struct. Defaults to the file name")
+	fs.BoolVar(&gormModel, "gorm", true, "embed gorm.Model in the generated struct")
+	fileName := parseArgs(fs, args)
+
+	file, sep := cf.openCsv(fileName)
+	defer file.Close()
+
+	cols, _, err := csv_to_gorm.InferColumns(file, sep, cf.params())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if typeName == "" {
+		typeName = typeNameFromFile(fileName)
+	}
+	src, err := csv_to_gorm.GenerateStruct(typeName, cols, gormModel)
+	if err != nil {
+		log.Fatal("could not generate struct: ", err)
+	}
+	os.Stdout.Write(src)
+}
+
+// typeNameFromFile turns e.g. pest_losses.csv into PestLosses
+func typeNameFromFile(fileName string) string {
+	base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	return csv_to_gorm.FieldNames([]string{base})[0]
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/c4rnot/csv_to_gorm"
+)
+
+func inspectCmd(args []string) {
+	var cf csvFlags
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	cf.register(fs)
+	fileName := parseArgs(fs, args)
+
+	file, sep := cf.openCsv(fileName)
+	defer file.Close()
+
+	headings, err := csv_to_gorm.GetHeadings(file, sep)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cols, dataRows, err := csv_to_gorm.InferColumns(file, sep, cf.params())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("file:      ", fileName)
+	fmt.Println("separator: ", sepName(sep))
+	fmt.Println("columns:   ", len(headings))
+	fmt.Println("data rows: ", dataRows)
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COL\tHEADING\tTYPE\tEMPTY\tSAMPLE")
+	for _, col := range cols {
+		colID, _ := csv_to_gorm.ExcelColNoToColId(col.ColNo)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", colID, col.Heading, col.Kind, col.EmptyCells, col.Sample)
+	}
+	w.Flush()
+}
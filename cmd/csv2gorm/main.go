@@ -0,0 +1,144 @@
+// csv2gorm inspects CSV files and loads them into a database without having to write a go program
+//
+// usage:
+//
+//	csv2gorm inspect  [-sep ;] file.csv
+//	csv2gorm infer    [-sep ;] [-type Name] file.csv
+//	csv2gorm validate [-sep ;] -mapping mapping.json file.csv
+//	csv2gorm import   [-sep ;] [-mapping mapping.json] [-mode upsert -keys name] -driver sqlite -dsn data.db -table apples file.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/c4rnot/csv_to_gorm"
+)
+
+var commands = map[string]func(args []string){
+	"inspect":  inspectCmd,
+	"infer":    inferCmd,
+	"validate": validateCmd,
+	"import":   importCmd,
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("csv2gorm: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	cmd(os.Args[2:])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: csv2gorm <command> [flags] <file.csv>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  inspect   show the separator, headings, row count and inferred column types")
+	fmt.Fprintln(os.Stderr, "  infer     write a go struct able to hold the rows of the file")
+	fmt.Fprintln(os.Stderr, "  validate  convert every row using a mapping file and report the cells which fail")
+	fmt.Fprintln(os.Stderr, "  import    load the file into a database table")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "run csv2gorm <command> -h for the flags of a command")
+	os.Exit(2)
+}
+
+// csvFlags are the flags shared by all commands
+type csvFlags struct {
+	sep             string
+	firstRowHasData bool
+}
+
+func (c *csvFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.sep, "sep", "", "column separator. Guessed from the file if not given. Use \"tab\" for tab separated files")
+	fs.BoolVar(&c.firstRowHasData, "noheader", false, "the first row of the file holds data rather than headings")
+}
+
+// parseArgs parses the flags of a command and returns the single CSV file argument
+func parseArgs(fs *flag.FlagSet, args []string) string {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "expected exactly one CSV file")
+		fs.Usage()
+		os.Exit(2)
+	}
+	return fs.Arg(0)
+}
+
+// openCsv opens the file and works out which separator it uses
+func (c *csvFlags) openCsv(fileName string) (*os.File, rune) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch strings.ToLower(c.sep) {
+	case "":
+		sep, err := csv_to_gorm.GuessSeparator(file)
+		if err != nil {
+			log.Fatal("could not guess separator, use -sep: ", err)
+		}
+		return file, sep
+	case "tab", "\\t":
+		return file, '\t'
+	}
+	sepRunes := []rune(c.sep)
+	if len(sepRunes) != 1 {
+		log.Fatal("separator must be a single character")
+	}
+	return file, sepRunes[0]
+}
+
+func (c *csvFlags) params() csv_to_gorm.Params {
+	return csv_to_gorm.Params{
+		FirstRowHasData: c.firstRowHasData,
+		Logger:          stderrLogger{},
+	}
+}
+
+// sepName makes separators readable when printed
+func sepName(sep rune) string {
+	switch sep {
+	case '\t':
+		return "tab"
+	case ' ':
+		return "space"
+	}
+	return string(sep)
+}
+
+// stderrLogger writes the warnings and errors of csv_to_gorm to stderr
+type stderrLogger struct{}
+
+func (stderrLogger) Debug(msg string, args ...interface{})   {}
+func (stderrLogger) Info(msg string, args ...interface{})    {}
+func (l stderrLogger) Warn(msg string, args ...interface{})  { l.print(msg, args) }
+func (l stderrLogger) Error(msg string, args ...interface{}) { l.print(msg, args) }
+
+func (stderrLogger) print(msg string, args []interface{}) {
+	for ix := 0; ix+1 < len(args); ix += 2 {
+		msg += fmt.Sprintf(" %v=%v", args[ix], args[ix+1])
+	}
+	log.Println(msg)
+}
+
+// printProgress shows how far through the file an import has got
+func printProgress(p csv_to_gorm.Progress) {
+	if p.TotalBytes > 0 {
+		fmt.Fprintf(os.Stderr, "\r%d rows, %d records, %d%%", p.RowsRead, p.Records, p.BytesRead*100/p.TotalBytes)
+	} else {
+		fmt.Fprintf(os.Stderr, "\r%d rows, %d records", p.RowsRead, p.Records)
+	}
+	if p.Done {
+		fmt.Fprintln(os.Stderr)
+	}
+}
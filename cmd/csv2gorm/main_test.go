@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/c4rnot/csv_to_gorm"
+)
+
+func TestTypeNameFromFile(t *testing.T) {
+	tests := map[string]string{
+		"pest_losses.csv":         "PestLosses",
+		"/data/biggest exporters": "BiggestExporters",
+		"2021.csv":                "Col2021",
+	}
+	for fileName, want := range tests {
+		if got := typeNameFromFile(fileName); got != want {
+			t.Errorf("%s: got %s, want %s", fileName, got, want)
+		}
+	}
+}
+
+func TestSepName(t *testing.T) {
+	tests := map[rune]string{'\t': "tab", ' ': "space", ';': ";"}
+	for sep, want := range tests {
+		if got := sepName(sep); got != want {
+			t.Errorf("%q: got %s, want %s", sep, got, want)
+		}
+	}
+}
+
+func TestMappedModelType(t *testing.T) {
+	mapping, err := csv_to_gorm.ParseMapping([]byte(`{"fields": [{"name": "Name", "col": "Variety", "type": "string"}, {"name": "Found", "type": "int"}]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := mappedModelType(mapping, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.NumField() != 3 || typ.Field(0).Name != "Model" || typ.Field(1).Name != "Name" || typ.Field(2).Type.Name() != "int" {
+		t.Errorf("built %s", typ)
+	}
+
+	untyped, err := csv_to_gorm.ParseMapping([]byte(`{"fields": [{"name": "Name", "col": "Variety"}]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mappedModelType(untyped, false); err == nil {
+		t.Error("built a model for a field without a type")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/c4rnot/csv_to_gorm"
+	"gorm.io/gorm"
+)
+
+// mappedModelType builds a struct type holding the fields of a mapping file.
+// As there is no go struct to hold the roles of the fields, every field of the mapping needs a type
+func mappedModelType(m *csv_to_gorm.Mapping, gormModel bool) (reflect.Type, error) {
+	structFlds, err := m.StructFields()
+	if err != nil {
+		return nil, err
+	}
+	if gormModel {
+		structFlds = append([]reflect.StructField{gormModelField()}, structFlds...)
+	}
+	return reflect.StructOf(structFlds), nil
+}
+
+func gormModelField() reflect.StructField {
+	return reflect.StructField{
+		Name:      "Model",
+		Type:      reflect.TypeOf(gorm.Model{}),
+		Anonymous: true,
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+
+	"github.com/c4rnot/csv_to_gorm"
+)
+
+func validateCmd(args []string) {
+	var cf csvFlags
+	var mappingFile string
+	var maxErrs int
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&mappingFile, "mapping", "", "mapping file (YAML or JSON) describing the fields (required)")
+	fs.IntVar(&maxErrs, "max", 100, "print at most this many errors. 0 for no limit")
+	fileName := parseArgs(fs, args)
+	if mappingFile == "" {
+		log.Fatal("validate needs a -mapping file")
+	}
+
+	m, err := csv_to_gorm.LoadMapping(mappingFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	modelTyp, err := mappedModelType(m, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	file, sep := cf.openCsv(fileName)
+	defer file.Close()
+
+	params := cf.params()
+	params.Mapping = m
+
+	// a dry run: the records are converted but go nowhere
+	records, err := csv_to_gorm.CsvToSlice(file, sep, reflect.New(modelTyp).Interface(), params)
+	recordCount := reflect.ValueOf(records).Len()
+	if err == nil {
+		fmt.Printf("%d records converted without errors\n", recordCount)
+		return
+	}
+
+	var errs interface{ Unwrap() []error }
+	cellErrs := []error{err}
+	if errors.As(err, &errs) {
+		cellErrs = errs.Unwrap()
+	}
+	for ix, cellErr := range cellErrs {
+		if maxErrs > 0 && ix >= maxErrs {
+			fmt.Printf("... and %d more\n", len(cellErrs)-maxErrs)
+			break
+		}
+		fmt.Println(cellErr)
+	}
+	fmt.Printf("%d records converted, %d errors\n", recordCount, len(cellErrs))
+	os.Exit(1)
+}
@@ -0,0 +1,97 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// every entry point gives up straight away with a context already cancelled
+func TestContextCancelled(t *testing.T) {
+	content := "Name;Diameter;Found;Organic\nCox;6.5;1825;true\n"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cols := []ColumnInfo{{Heading: "Name", Name: "name", ColNo: 1, Kind: reflect.String}}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T) error
+	}{
+		{"CsvToSliceContext", func(t *testing.T) error {
+			_, err := CsvToSliceContext(ctx, tempCSV(t, content), ';', &testFruit{}, Params{})
+			return err
+		}},
+		{"CsvToMapsContext", func(t *testing.T) error {
+			_, err := CsvToMapsContext(ctx, tempCSV(t, content), ';', cols, Params{})
+			return err
+		}},
+		{"GuessSeparatorContext", func(t *testing.T) error {
+			_, err := GuessSeparatorContext(ctx, tempCSV(t, content))
+			return err
+		}},
+		{"InferColumnsContext", func(t *testing.T) error {
+			_, _, err := InferColumnsContext(ctx, tempCSV(t, content), ';', Params{})
+			return err
+		}},
+		{"ImportContext", func(t *testing.T) error {
+			_, err := ImportContext(ctx, testDB(t), tempCSV(t, content), ';', &testFruit{}, ImportParams{Migrate: true})
+			return err
+		}},
+		{"Import with the context of the db", func(t *testing.T) error {
+			_, err := Import(testDB(t).WithContext(ctx), tempCSV(t, content), ';', &testFruit{}, ImportParams{Migrate: true})
+			return err
+		}},
+		{"ImportTableContext", func(t *testing.T) error {
+			_, err := ImportTableContext(ctx, testDB(t), "fruits", tempCSV(t, content), ';', ImportParams{Migrate: true})
+			return err
+		}},
+		{"ImportGraphContext", func(t *testing.T) error {
+			_, err := ImportGraphContext(ctx, testDB(t), tempCSV(t, content), ';', &testFruit{}, ImportParams{Migrate: true})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(t); !errors.Is(err, context.Canceled) {
+				t.Errorf("got error %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+// an import cancelled part way keeps the batches already written and says where it stopped
+func TestImportCancelledPartWay(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Name;Diameter;Found;Organic\n")
+	for i := 0; i < 25; i++ {
+		fmt.Fprintf(&sb, "Apple %d;6.5;%d;true\n", i, 1800+i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	records := 0
+	params := ImportParams{BatchSize: 5, Migrate: true, Params: Params{RecordFilter: func(record interface{}) bool {
+		records++
+		if records == 7 {
+			cancel()
+		}
+		return true
+	}}}
+
+	db := testDB(t)
+	result, err := ImportContext(ctx, db, tempCSV(t, sb.String()), ';', &testFruit{}, params)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	checkErrs(t, err, []string{"stopped after row 8"})
+	if result.Inserted != 5 {
+		t.Errorf("%d records inserted, want the first batch of 5", result.Inserted)
+	}
+	var count int64
+	db.Model(&testFruit{}).Count(&count)
+	if count != 5 {
+		t.Errorf("table holds %d records, want 5", count)
+	}
+}
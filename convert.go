@@ -0,0 +1,157 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Converter turns the text of a cell into a value of the field's type.
+// Converters are registered by name with RegisterConverter and chosen with the conv: tag
+type Converter func(input string, outType reflect.Type, params Params) (reflect.Value, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = make(map[string]Converter)
+)
+
+// RegisterConverter makes a converter available to the conv: tag and the conv attribute of mapping files
+func RegisterConverter(name string, converter Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[name] = converter
+	forgetPlans()
+}
+
+func lookupConverter(name string) (Converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	converter, ok := converters[name]
+	return converter, ok
+}
+
+// value checks the cell against the field's validation rules and converts it to the type of the field
+func (f *fieldPlan) value(cell string, params Params) (value reflect.Value, err error) {
+	// a converter which panics should spoil the cell, not the whole file
+	defer func() {
+		if r := recover(); r != nil {
+			value = reflect.Zero(f.field.Type)
+			err = fmt.Errorf("field %s: could not convert %q: %v", f.field.Name, cell, r)
+		}
+	}()
+
+	if f.transforms != nil {
+		if cell, err = f.transform(cell); err != nil {
+			return reflect.Zero(f.field.Type), fmt.Errorf("field %s: %w", f.field.Name, err)
+		}
+	}
+	if err := f.checkCell(cell); err != nil {
+		return reflect.Zero(f.field.Type), fmt.Errorf("field %s: %w", f.field.Name, err)
+	}
+	var mapped bool
+	if cell, mapped, err = f.mapValue(cell, params); err != nil {
+		return reflect.Zero(f.field.Type), fmt.Errorf("field %s: %w", f.field.Name, err)
+	}
+	if f.tag.Bools != nil {
+		params.Bools = f.tag.Bools
+	}
+	if mapped {
+		// the values of a value map are true or false, whatever words the cells of the file use
+		params.Bools, params.StrictBools = mappedBools, true
+	}
+	if f.tag.Split != "" {
+		params.ListSep = f.tag.Split
+	}
+	if f.lookup != nil {
+		value, err = f.lookupValue(cell, params)
+	} else {
+		value, err = f.convert(cell, f.field.Type, params)
+	}
+	if err == errSkipRecord {
+		return reflect.Zero(f.field.Type), err
+	}
+	if err != nil {
+		return reflect.Zero(f.field.Type), fmt.Errorf("field %s: %w", f.field.Name, err)
+	}
+	if err := f.tag.checkValue(value); err != nil {
+		return reflect.Zero(f.field.Type), fmt.Errorf("field %s: %w", f.field.Name, err)
+	}
+	return value, nil
+}
+
+// errSkipRecord is returned for a cell which means its record should be left out without an error
+var errSkipRecord = errors.New("skip record")
+
+// lookupValue finds the key of the parent the cell refers to
+func (f *fieldPlan) lookupValue(cell string, params Params) (reflect.Value, error) {
+	if params.Lookups == nil {
+		return reflect.Zero(f.field.Type), errors.New("lookup needs the Lookups of the params to be set")
+	}
+	if strings.TrimSpace(cell) == "" && f.field.Type.Kind() == reflect.Ptr {
+		return reflect.Zero(f.field.Type), nil
+	}
+	key, found, err := params.Lookups.resolve(*f.lookup, cell)
+	if err != nil {
+		return reflect.Zero(f.field.Type), err
+	}
+	if !found {
+		return reflect.Zero(f.field.Type), errSkipRecord
+	}
+	return lookupValue(key, f.field.Type)
+}
+
+// checkCell applies the validation rules which look at the text of the cell
+func (f *fieldPlan) checkCell(cell string) error {
+	if f.tag.Required && strings.TrimSpace(cell) == "" {
+		return errors.New("value is required")
+	}
+	if len(f.tag.OneOf) > 0 {
+		if _, ok := find(f.tag.OneOf, cell); !ok {
+			return fmt.Errorf("%q is not one of %s", cell, strings.Join(f.tag.OneOf, ", "))
+		}
+	}
+	if f.pattern != nil && !f.pattern.MatchString(cell) {
+		return fmt.Errorf("%q does not match %s", cell, f.tag.Pattern)
+	}
+	return nil
+}
+
+// checkValue applies the validation rules which look at the converted value
+func (tag Tag) checkValue(value reflect.Value) error {
+	if tag.Min == nil && tag.Max == nil {
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	var number float64
+	switch value.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		number = float64(value.Int())
+	case reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		number = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		number = value.Float()
+	default:
+		switch exact := value.Interface().(type) {
+		case Decimal:
+			number = exact.Float64()
+		case Money:
+			number = exact.Amount.Float64()
+		default:
+			return errors.New("min and max can only be used with numeric fields")
+		}
+	}
+	if tag.Min != nil && number < *tag.Min {
+		return fmt.Errorf("%v is less than the minimum of %v", number, *tag.Min)
+	}
+	if tag.Max != nil && number > *tag.Max {
+		return fmt.Errorf("%v is more than the maximum of %v", number, *tag.Max)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package csv_to_gorm
 
 import (
 	"bufio"
+	"context"
+	"encoding"
 	"encoding/csv"
 	"errors"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -26,9 +29,65 @@ import (
 * col: The column name associated with this field
 * intcols:colname  xtg will parse all columns whose column names  can parse as an integer.  A separate database record is created for each one
 * intcols:value  This field is the value associated with the column headed by an integer.
+* intcols:<group>  a part of the heading, split out by the headcols: parser, e.g. intcols:quarter
+* headcols:  picks out the intcols columns by something other than an integer heading.  One of
+*     integer         a whole number other than 0, which may have .0 after, e.g. 2020 or 2020.0.  Group number
+*     quarter         a year and quarter, e.g. 2020-Q1, 2020Q1 or Q1 2020.  Groups year and quarter
+*     date:<layout>   a date in the form of a go time layout, e.g. date:Jan 2006.  Groups year, month and day
+*     regex:<regexp>  headings matching the regular expression.  Groups are its numbered and named capture groups
+*     intcols:colname fields get the whole heading, with integers, quarters and dates written the same way each time
 * melt:colname  takes all colums not declared with col: and creates a separate record for each
 * melt:value  takes value associated with colums not declared with col:
+* melt:<group>:colname / melt:<group>:value  a named melt group, taking the columns picked out by a meltcols: sub-tag
+*     on one of its fields.  The groups are lined up by member, so that a row with cost and loss columns for each
+*     pest makes one record per pest holding both.  A group with no column for a member leaves its value field unset
+* meltcols:  picks out the columns of a named melt group, and the member each is for.  One of
+*     prefix:<prefix>             headings starting with the prefix.  The member is the rest of the heading
+*     list:<heading>;<heading>    the headings listed.  The member is the heading, or <member> for <heading>=<member>
+*     regex:<regexp>              headings matching.  The member is the first capture group, or else the heading
+*     Columns are taken by the first group which picks them out.  Plain melt:value fields melt the columns left over
+* meltsplit:  splits melt headings into a member and a measure, the name of the melt group the column belongs to,
+*     so that "Fire Blight (loss)" and "Fire Blight (cost)" fill the loss and cost fields of one record.  One of
+*     delim:<delimiter>  splits at the last delimiter, with the measure after it
+*     regex:<regexp>     the groups named member and measure, or else the first two groups
+*     Groups given a meltcols: take their columns first.  Measures are matched to group names ignoring case
 * ignore:  takes a ; separated list of strings.  These columns are ignored for melt
+* alias:  takes a ; separated list of other headings the col: column may have.  The first one found is used
+* conv:  the name of a converter registered with RegisterConverter, used instead of StringToType
+* required  the cell must not be empty
+* min: / max:  the lowest / highest number a numeric field may hold
+* oneOf:  takes a ; separated list of the only values the cell may have
+* transform:  a ; separated chain of transforms the cell is passed through before it is checked and converted.
+*     Each is <name> or <name>=<arg>, e.g. transform:trim;strip-suffix= cm;lower.  Built in are
+*     trim (or trim=<characters>), lower, upper, strip-suffix=<suffix>, strip-prefix=<prefix>,
+*     replace=<old>-><new>, regex-extract=<regexp> (the first group, or the whole match) and
+*     map-values=<name> (a table from the mapping file or RegisterValueMap).  Others are added with RegisterTransform
+* values:  a ; separated map of the only cells allowed to what each stands for, e.g. values:J=true;N=false or
+*     values:W=Winter;C=Cooking;E=Eating.  Other cells are errors.  The values are converted to the type of the field,
+*     which for an integer enum type may be the name of a constant registered with RegisterEnum
+* bools:  a ; separated list of the words for true and false in the cells of a bool field, replacing the
+*     Bools of the params.  Any of en (true/yes/y/t), de (wahr/ja/j), fr (vrai/oui/o), es (verdadero/sí/si/s),
+*     01 and x (x for true, blank for false), e.g. bools:de;01.  Matched ignoring case
+* split:  separates the items of a cell read into a slice or array field, e.g. split:| for apple|pear|plum,
+*     replacing the ListSep of the params.  Cells holding a JSON array, such as [1,2,3], are read as JSON.
+*     A comma separator must go in a mapping file.  See StringArray for storing slices with gorm
+* pattern:  a regular expression the cell has to match.  Patterns containing commas must go in a mapping file
+* meta:  filled with where the record came from rather than from a cell.  One of
+*     rownum     the row number in the file, starting at 1 with the heading row
+*     filename   the name of the file, as it was opened
+*     sheet      the Sheet of the params, for files exported from a workbook
+*     importid   the ImportID of the params, or an ID made up for each read of the file if not set
+*     sourcecol  the column number, starting at 1, of the intcols or melt column the record was made from
+* lookup:  the cell is looked up in a parent table, and the field set to the key of the row found.
+*     In the form lookup:<Model>.<Field>-><Field>;<fail|skip|create>, e.g. lookup:Apple.Name->ID;create
+*     The key field defaults to ID.  What happens when no parent matches defaults to the Missing of the Lookups
+* assoc  the field is a gorm association (a struct, pointer to a struct or slice of structs) whose records are
+*     made from the same row, using the xtg tags of their own model.  Slices take every record the row makes
+* dropempty  records of the model whose intcols and melt value cells are all empty (see Params.NullTokens) are
+*     left out and counted as skipped.  Applies to the whole model, whichever field it is on
+* key  the field is part of the key which identifies a record when importing with a Mode other than InsertOnly
+*
+* the same instructions can be given in a mapping file (see Mapping) rather than in tags
  */
 
 type Tag struct {
@@ -42,14 +101,75 @@ type Tag struct {
 	IsMeltHead     bool
 	IsMeltValue    bool
 	Ignore         []string
+	Aliases        []string // other headings the Colname column may have
+	ColNo          int      // column number starting at 1.  Only settable through a mapping file
+	Converter      string   // name of a registered converter
+	Required       bool
+	Min            *float64
+	Max            *float64
+	OneOf          []string
+	Pattern        string
+	IsKey          bool
+	Meta           string
+	Lookup         string
+	IsAssoc        bool
+	IntColsGroup   string            // part of the heading an intcols field is set to
+	HeadCols       string            // how intcols columns are picked out, such as quarter or date:Jan 2006
+	MeltGroup      string            // name of the melt group of a melt field, empty for the plain melt
+	MeltCols       string            // how the columns of the melt group are picked out, such as prefix:Cost
+	DropEmpty      bool              // the model leaves out records whose value cells are all empty
+	MeltSplit      string            // how melt headings split into a member and a measure, such as delim:_
+	Transform      []string          // steps the cell is passed through before being checked and converted
+	Values         map[string]string // the only cells allowed, and what each stands for
+	Bools          []BoolWords       // the words for true and false, replacing the Bools of the params
+	Split          string            // separates the items of slice and array fields, replacing the ListSep of the params
 }
 
 type Params struct {
 	ColMap          map[string]int    // maps fieldnames to column numbers(starting at 1).  Overrides tagnames if mapping present
 	ConstMap        map[string]string // maps from tagname mapConst:Mapfrom to a string constant to be parsed into the field
+	Mapping         *Mapping          // mapping file, which adds to and overrides the xtg tags of the model
 	FirstRowHasData bool
 	ErrorOnNaN      bool
-	//ErrorOnInf bool
+	Workers         int            // number of goroutines converting rows.  0 or 1 converts them on the calling goroutine
+	Unordered       bool           // with Workers, records may come out in a different order to the rows of the file, which is faster
+	Logger          Logger         // receives messages about the file, such as missing headers.  Nothing is logged if nil
+	Progress        func(Progress) // called every 10,000 rows and once the file has been read
+	Sheet           string         // sheet the file was exported from, for meta:sheet fields
+	ImportID        string         // identifies this read of the file, for meta:importid fields and the import log
+	Lookups         *Lookups       // resolves lookup: fields.  Import makes one from its db if not set
+	EmptyValues     EmptyValues    // what happens to records made from empty intcols and melt value cells
+	NullTokens      []string       // cells taken as empty as well as blank ones, e.g. NA or -
+	// ValueMaps gives fields a value map by field name, replacing any in the tag or mapping (see values:)
+	ValueMaps map[string]map[string]string
+	// Bools are the words for true and false in bool cells, matched ignoring case, e.g.
+	// []BoolWords{GermanBools, NumericBools}.  English and 0/1 if not set.  The bools: sub-tag overrides them
+	Bools []BoolWords
+	// StrictBools makes bool cells in neither the true nor the false words errors rather than false
+	StrictBools bool
+	// ListSep separates the items of cells read into slice and array fields, ; if not set.  The split: sub-tag
+	// overrides it.  Cells holding a JSON array, such as [1,2,3], are read as JSON whatever the separator
+	ListSep string
+	// IntegralFloats lets integer fields take whole numbers written as floats, such as 1960.0 or 1e3
+	IntegralFloats bool
+	// IntPrefixes lets integer fields take hexadecimal, octal and binary numbers starting 0x, 0o or 0b
+	IntPrefixes bool
+	// RowFilter is called with each row of data before its cells are converted.  Rows for which it returns false
+	// are left out, and counted as filtered rather than rejected.  With Workers, it is called from several goroutines
+	RowFilter func(row Row) bool
+	// RecordFilter is called with each record built, the model struct by value, such as a Yield rather than
+	// a *Yield.  Records for which it returns false are left out and counted as filtered
+	RecordFilter func(record interface{}) bool
+	// Percents says what happens to float cells ending in %.  By default they are divided by 100
+	Percents Percents
+	// Infinities says what infinite float cells become.  By default the largest number the field can hold
+	Infinities Infinities
+	ErrorOnInf bool     // infinite float cells are errors
+	InfTokens  []string // cells meaning infinity, with or without a sign, matched ignoring case.  inf, infinity and ∞ if not set
+	NaNTokens  []string // cells read as NaN, even with ErrorOnNaN, matched ignoring case, e.g. n/a
+	// DecimalSep says whether Decimal and Money cells have a decimal point or a decimal comma.  If not set it is
+	// worked out from each cell, and cells such as 1,234 or 1.234, which could have either, are errors
+	DecimalSep DecimalSeparator
 }
 
 func ParseTag(field reflect.StructField) (Tag, error) {
@@ -83,17 +203,32 @@ func ParseTag(field reflect.StructField) (Tag, error) {
 			if len(subTagElements) < 2 {
 				return tag, errors.New("whether field is heading or value field : " + field.Name + ". should be in the form intcols:colname or intcols:value")
 			}
-			if strings.ToLower(subTagElements[1]) == "colname" {
+			switch strings.ToLower(subTagElements[1]) {
+			case "colname":
 				tag.IsIntColsHead = true
-				tag.IsIntColsValue = false
-			} else {
-				tag.IsIntColsHead = false
+			case "value":
 				tag.IsIntColsValue = true
+			default:
+				tag.IntColsGroup = subTagElements[1]
+			}
+		case "headcols":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("heading parser missing for field: " + field.Name + ". should be in the form headcols:<integer|quarter|date:layout|regex:regexp>")
+			}
+			// date layouts and regular expressions may themselves contain colons
+			tag.HeadCols = strings.Join(subTagElements[1:], ":")
+			if _, err := parseHeadCols(tag.HeadCols); err != nil {
+				return tag, errors.New("field " + field.Name + ": " + err.Error())
 			}
 		case "melt":
 			if len(subTagElements) < 2 {
 				return tag, errors.New("whether field is heading or value field : " + field.Name + ". should be in the form melt:colname or melt:value")
 			}
+			if len(subTagElements) > 2 {
+				// a named group, melt:<group>:colname
+				tag.MeltGroup = subTagElements[1]
+				subTagElements = subTagElements[1:]
+			}
 			if strings.ToLower(subTagElements[1]) == "colname" {
 				tag.IsMeltHead = true
 				tag.IsMeltValue = false
@@ -101,12 +236,115 @@ func ParseTag(field reflect.StructField) (Tag, error) {
 				tag.IsMeltHead = false
 				tag.IsMeltValue = true
 			}
+		case "meltcols":
+			if len(subTagElements) < 3 {
+				return tag, errors.New("melt columns missing for field: " + field.Name + ". should be in the form meltcols:<prefix|list|regex>:<columns>")
+			}
+			// regular expressions may themselves contain colons
+			tag.MeltCols = strings.Join(subTagElements[1:], ":")
+		case "values":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("values missing for field: " + field.Name + ". should be in the form values:<cell>=<value>;<cell>=<value>")
+			}
+			values, err := parseValueMap(strings.Join(subTagElements[1:], ":"))
+			if err != nil {
+				return tag, errors.New("field " + field.Name + ": " + err.Error())
+			}
+			tag.Values = values
+		case "bools":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("vocabularies missing for field: " + field.Name + ". should be in the form bools:<en|de|fr|es|01|x>;<...>")
+			}
+			bools, err := parseBools(subTagElements[1])
+			if err != nil {
+				return tag, errors.New("field " + field.Name + ": " + err.Error())
+			}
+			tag.Bools = bools
+		case "split":
+			if len(subTagElements) < 2 || subTagElements[1] == "" {
+				return tag, errors.New("separator missing for field: " + field.Name + ". should be in the form split:<separator>")
+			}
+			// the separator may itself be a colon
+			tag.Split = strings.Join(subTagElements[1:], ":")
+		case "transform":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("transforms missing for field: " + field.Name + ". should be in the form transform:<name>;<name>=<arg>")
+			}
+			// arguments may themselves contain colons
+			tag.Transform = strings.Split(strings.Join(subTagElements[1:], ":"), ";")
+		case "meltsplit":
+			if len(subTagElements) < 3 {
+				return tag, errors.New("heading split missing for field: " + field.Name + ". should be in the form meltsplit:<delim|regex>:<delimiter or regexp>")
+			}
+			// delimiters and regular expressions may themselves contain colons
+			tag.MeltSplit = strings.Join(subTagElements[1:], ":")
+			if _, err := parseMeltSplit(tag.MeltSplit); err != nil {
+				return tag, errors.New("field " + field.Name + ": " + err.Error())
+			}
 		case "ignore":
 			if len(subTagElements) == 1 {
 				continue
 			}
 			ignoreStrings := strings.Split(subTagElements[1], ";")
 			tag.Ignore = ignoreStrings
+		case "alias":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("alias missing for field: " + field.Name + ". should be in the form alias:<colname>;<colname>")
+			}
+			tag.Aliases = strings.Split(subTagElements[1], ";")
+		case "conv":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("converter name missing for field: " + field.Name + ". should be in the form conv:<name>")
+			}
+			tag.Converter = subTagElements[1]
+		case "required":
+			tag.Required = true
+		case "dropempty":
+			tag.DropEmpty = true
+		case "key":
+			tag.IsKey = true
+		case "assoc":
+			tag.IsAssoc = true
+		case "lookup":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("lookup missing for field: " + field.Name + ". should be in the form lookup:<Model>.<Field>-><Field>")
+			}
+			if _, err := parseLookup(subTagElements[1]); err != nil {
+				return tag, errors.New("field " + field.Name + ": " + err.Error())
+			}
+			tag.Lookup = subTagElements[1]
+		case "meta":
+			if len(subTagElements) < 2 || !isMeta(subTagElements[1]) {
+				return tag, errors.New("meta role missing or unknown for field: " + field.Name + ". should be one of meta:rownum, meta:filename, meta:sheet, meta:importid or meta:sourcecol")
+			}
+			tag.Meta = subTagElements[1]
+		case "min", "max":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("limit missing for field: " + field.Name + ". should be in the form " + subTagElements[0] + ":<number>")
+			}
+			limit, err := strconv.ParseFloat(subTagElements[1], 64)
+			if err != nil {
+				return tag, errors.New("limit for field: " + field.Name + " is not a number: " + subTagElements[1])
+			}
+			if subTagElements[0] == "min" {
+				tag.Min = &limit
+			} else {
+				tag.Max = &limit
+			}
+		case "oneOf":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("values missing for field: " + field.Name + ". should be in the form oneOf:<value>;<value>")
+			}
+			tag.OneOf = strings.Split(subTagElements[1], ";")
+		case "pattern":
+			if len(subTagElements) < 2 {
+				return tag, errors.New("pattern missing for field: " + field.Name + ". should be in the form pattern:<regexp>")
+			}
+			// the pattern may itself contain colons
+			tag.Pattern = strings.Join(subTagElements[1:], ":")
+			if _, err := regexp.Compile(tag.Pattern); err != nil {
+				return tag, errors.New("pattern for field: " + field.Name + " is not a valid regular expression: " + err.Error())
+			}
 		}
 	}
 	return tag, nil
@@ -114,289 +352,56 @@ func ParseTag(field reflect.StructField) (Tag, error) {
 
 // converts the content of a CSV file to a slice of 'models'
 // colMap maps the feldnames of the model to the column numbers (beginning at 1) of the CSV file
-// the result is an interface, which will need to be typecast by the caller
+// the result is an interface, which will need to be typecast by the caller.
+// Rows with cells which cannot be converted are left out, as Import leaves them out, and their errors
+// returned with the records of the other rows
 func CsvToSlice(file *os.File, colSep rune, model interface{}, params Params) (dataSlice interface{}, err error) {
-	// map of column headings to 1 based column numbers (for consistency with csv_to_gorm)
-	var lclColMap map[string]int
-	var definedCols []string
-	var hasIntCols bool
-	var intColHdgs []string
-	var hasMelt bool
-	var meltColHdgs []string
-	var ignore []string
-	var errs error
-	var maxCol int
+	return CsvToSliceContext(context.Background(), file, colSep, model, params)
+}
 
-	// make sure we start at teh start of the file
-	file.Seek(0, 0)
+// CsvToSliceContext is CsvToSlice, stopping with the context's error, and the records read so far,
+// if the context is cancelled before the end of the file
+func CsvToSliceContext(ctx context.Context, file *os.File, colSep rune, model interface{}, params Params) (dataSlice interface{}, err error) {
+	var errs error
 
 	// determine what type of model we are trying to fill records of
 	modelTyp := reflect.ValueOf(model).Elem().Type()
-	modelNumFlds := modelTyp.NumField()
 
 	// make an empty slice to hold the records to be uploaded to the db.
 	// ***  TODO  Speed up by FIRST DETERMINE HOW BIG THE ARRAY HAS TO BE  **
 	objSlice := reflect.Zero(reflect.SliceOf(modelTyp))
 
-	r := csv.NewReader(file)
-	r.Comma = colSep
-
-	var recordIx int = 0
-	// for each line of the CSV file, which is a record
-	dbRecordPtr := reflect.New(modelTyp)
-	for {
-		if recordIx%10000 == 0 {
-			fmt.Println("Processing record No.", recordIx)
+	err = readRows(ctx, file, colSep, modelTyp, params, nil, func(res rowResult) error {
+		for _, err := range res.errs {
+			errs = appendErr(errs, err)
 		}
-		csvRecord, err := r.Read()
-		if err == io.EOF {
-			fmt.Println("Reached end of input file")
-			break
-		}
-
-		// Get headings from first row if necessary
-		if recordIx == 0 {
-			if !params.FirstRowHasData {
-				lclColMap = mapHeadingToCol(csvRecord)
-				intColHdgs = getIntCols(csvRecord)
-				maxCol = len(csvRecord) // counting from 1
-
-				// check if there is an intcol tag, as a db entry has to be made for each int col
-				for fldIx := 0; fldIx < modelNumFlds; fldIx++ {
-					tag, err := ParseTag(modelTyp.Field(fldIx))
-					if err != nil {
-						errs = fmt.Errorf("could not parse tag for :  "+modelTyp.Name()+". ", errs)
-					}
-					if tag.IsIntColsHead || tag.IsIntColsValue {
-						hasIntCols = true
-					}
-					if tag.IsMeltHead || tag.IsMeltValue {
-						hasMelt = true
-					}
-					if len(tag.Ignore) > 0 {
-						ignore = append(ignore, tag.Ignore...)
-					}
-					if tag.HasColanme {
-						definedCols = append(definedCols, tag.Colname)
-					}
-				}
-				if !hasMelt {
-					meltColHdgs = []string{}
-				} else {
-					meltColHdgs = getMeltCols(csvRecord, params.ColMap, definedCols, ignore, hasIntCols, intColHdgs)
-				}
-				// first row has no data, so skip to next row
-				recordIx++
-				continue
-			}
-			// any other special first row code for case where where data is in first row here
+		if len(res.errs) > 0 {
+			// the row is rejected, rather than giving records with zero values for the cells in error
+			return nil
 		}
-
-		// create the new item to add to the database
-		dbRecordPtr = reflect.New(modelTyp)
-
-		if hasIntCols && !hasMelt {
-			for _, intColHdg := range intColHdgs {
-				// create the new item to add to the database
-				dbRecordPtr = reflect.New(modelTyp)
-
-				// for each field in the model
-				for fldIx := 0; fldIx < modelNumFlds; fldIx++ {
-					fld := modelTyp.Field(fldIx)
-					fldName := fld.Name
-					fldType := fld.Type
-					tag, _ := ParseTag(fld)
-
-					paramsCol := params.ColMap[fldName]
-					if paramsCol > maxCol {
-						log.Fatal("Column supplied in map is out of range")
-					}
-					// if a parameter column maps to the field
-					if paramsCol > 0 {
-						cell := csvRecord[paramsCol-1]
-						dbRecordPtr.Elem().Field(fldIx).Set(StringToType(cell, fldType, params))
-					} else {
-						switch {
-						case tag.IsMapConst:
-							constString := params.ConstMap[tag.ConstMapKey]
-							// trying to convert empty strings to numbers in csv_to_gorm will bomb!
-							if constString == "" && fldType.Name() != "string" {
-								errs = fmt.Errorf("tag constant: "+tag.ConstMapKey+" missing for :  "+modelTyp.Name()+". ", errs)
-							} else {
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(constString, fldType, params))
-							}
-						case tag.IsIntColsHead:
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(intColHdg, fldType, params))
-						case tag.IsIntColsValue:
-							fmt.Println("colMapCol: ", lclColMap[intColHdg])
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[intColHdg]-1], fldType, params))
-						case tag.HasColanme:
-							if lclColMap[tag.Colname] == 0 {
-								fmt.Println("Could not find column header " + tag.Colname + " in: " + modelTyp.Name() + ". ")
-								errs = fmt.Errorf("Could not find column header "+tag.Colname+" in: "+modelTyp.Name()+". ", errs)
-							}
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[tag.Colname]-1], fldType, params))
-						}
-					}
-				}
-				// add the record to the slice of records
-				objSlice = reflect.Append(objSlice, dbRecordPtr.Elem())
-			}
-
-		} else if hasMelt && !hasIntCols {
-			for _, meltColHdg := range meltColHdgs {
-				// create the new item to add to the database
-				dbRecordPtr = reflect.New(modelTyp)
-
-				// for each field in the model
-				for fldIx := 0; fldIx < modelNumFlds; fldIx++ {
-					fld := modelTyp.Field(fldIx)
-					fldName := fld.Name
-					fldType := fld.Type
-					tag, _ := ParseTag(fld)
-
-					paramsCol := params.ColMap[fldName]
-					if paramsCol > maxCol {
-						log.Fatal("Column supplied in map is out of range")
-					}
-					// if a parameter column maps to the field
-					if paramsCol > 0 {
-						cell := csvRecord[paramsCol-1]
-						dbRecordPtr.Elem().Field(fldIx).Set(StringToType(cell, fldType, params))
-					} else {
-						switch {
-						case tag.IsMapConst:
-							constString := params.ConstMap[tag.ConstMapKey]
-							// trying to convert empty strings to numbers in csv_to_gorm will bomb!
-							if constString == "" && fldType.Name() != "string" {
-								errs = fmt.Errorf("tag constant: "+tag.ConstMapKey+" missing for :  "+modelTyp.Name()+". ", errs)
-							} else {
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(constString, fldType, params))
-							}
-						case tag.IsMeltHead:
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(meltColHdg, fldType, params))
-						case tag.IsMeltValue:
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[meltColHdg]-1], fldType, params))
-						case tag.HasColanme:
-							if lclColMap[tag.Colname] == 0 {
-								fmt.Println("Could not find column header " + tag.Colname + " in :  " + modelTyp.Name() + ". ")
-								errs = fmt.Errorf("Could not find column header "+tag.Colname+" in :  "+modelTyp.Name()+". ", errs)
-							}
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[tag.Colname]-1], fldType, params))
-						}
-					}
-				}
-				// add the record to the slice of records
-				// objArry.Index(recordIx).Set(reflect.ValueOf(dbRecordPtr.Elem().Interface()))
-				objSlice = reflect.Append(objSlice, dbRecordPtr.Elem())
-			}
-
-		} else if hasMelt && hasIntCols {
-			for _, intColHdg := range intColHdgs {
-				for _, meltColHdg := range meltColHdgs {
-					// create the new item to add to the database
-					dbRecordPtr = reflect.New(modelTyp)
-
-					// for each field in the model
-					for fldIx := 0; fldIx < modelNumFlds; fldIx++ {
-						fld := modelTyp.Field(fldIx)
-						fldName := fld.Name
-						fldType := fld.Type
-						tag, _ := ParseTag(fld)
-
-						paramsCol := params.ColMap[fldName]
-						if paramsCol > maxCol {
-							log.Fatal("Column supplied in map is out of range")
-						}
-						// if a parameter column maps to the field
-						if paramsCol > 0 {
-							cell := csvRecord[paramsCol-1]
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(cell, fldType, params))
-						} else {
-							switch {
-							case tag.IsMapConst:
-								constString := params.ConstMap[tag.ConstMapKey]
-								// trying to convert empty strings to numbers in csv_to_gorm will bomb!
-								if constString == "" && fldType.Name() != "string" {
-									errs = fmt.Errorf("tag constant: "+tag.ConstMapKey+" missing for :  "+modelTyp.Name()+". ", errs)
-								} else {
-									dbRecordPtr.Elem().Field(fldIx).Set(StringToType(constString, fldType, params))
-								}
-							case tag.IsMeltHead:
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(meltColHdg, fldType, params))
-							case tag.IsMeltValue:
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[meltColHdg]-1], fldType, params))
-							case tag.IsIntColsHead:
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(intColHdg, fldType, params))
-							case tag.IsIntColsValue:
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[intColHdg]-1], fldType, params))
-							case tag.HasColanme:
-								if lclColMap[tag.Colname] == 0 {
-									fmt.Println("Could not find column header " + tag.Colname + " in  :  " + modelTyp.Name() + ". ")
-									errs = fmt.Errorf("Could not find column header "+tag.Colname+" in  :  "+modelTyp.Name()+". ", errs)
-								}
-								dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[tag.Colname]-1], fldType, params))
-							}
-						}
-					}
-					// add the record to the slice of records
-					// objArry.Index(recordIx).Set(reflect.ValueOf(dbRecordPtr.Elem().Interface()))
-					objSlice = reflect.Append(objSlice, dbRecordPtr.Elem())
-				}
-			}
-
-		} else {
-			// for each field in the model
-			for fldIx := 0; fldIx < modelNumFlds; fldIx++ {
-				fld := modelTyp.Field(fldIx)
-				fldName := fld.Name
-				fldType := fld.Type
-				tag, _ := ParseTag(fld)
-
-				paramsCol := params.ColMap[fldName]
-				if paramsCol > maxCol {
-					log.Fatal("Column supplied in map is out of range")
-				}
-				// if a parameter column maps to the field
-				if paramsCol > 0 {
-					cell := csvRecord[paramsCol-1]
-					dbRecordPtr.Elem().Field(fldIx).Set(StringToType(cell, fldType, params))
-				} else {
-					switch {
-					case tag.IsMapConst:
-						constString := params.ConstMap[tag.ConstMapKey]
-						// trying to convert empty strings to numbers in csv_to_gorm will bomb!
-						if constString == "" && fldType.Name() != "string" {
-							errs = fmt.Errorf("tag constant: "+tag.ConstMapKey+" missing for :  "+modelTyp.Name()+". ", errs)
-						} else {
-							dbRecordPtr.Elem().Field(fldIx).Set(StringToType(constString, fldType, params))
-						}
-					case tag.HasColanme:
-						if lclColMap[tag.Colname] == 0 {
-							fmt.Println("Could not find column header " + tag.Colname + " in :  " + modelTyp.Name() + ". ")
-							errs = fmt.Errorf("Could not find column header "+tag.Colname+" in :  "+modelTyp.Name()+". ", errs)
-						}
-						dbRecordPtr.Elem().Field(fldIx).Set(StringToType(csvRecord[lclColMap[tag.Colname]-1], fldType, params))
-					}
-				}
-			}
-			// add the record to the slice of records
-			// objArry.Index(recordIx).Set(reflect.ValueOf(dbRecordPtr.Elem().Interface()))
-			objSlice = reflect.Append(objSlice, dbRecordPtr.Elem())
+		// add the records to the slice of records
+		for _, record := range res.records {
+			objSlice = reflect.Append(objSlice, record)
 		}
-
-		recordIx++
-
-	}
+		return nil
+	})
 	if err != nil {
 		return objSlice.Interface(), err
 	}
+	if errs != nil {
+		return objSlice.Interface(), errs
+	}
 
 	return objSlice.Interface(), nil
 }
 
 // inputReader would normally be the file or stream to read
 func GuessSeparator(file *os.File) (rune, error) {
+	return GuessSeparatorContext(context.Background(), file)
+}
+
+// GuessSeparatorContext is GuessSeparator, giving up if the context is cancelled
+func GuessSeparatorContext(ctx context.Context, file *os.File) (rune, error) {
 	// make sure we start at teh start of the file
 	file.Seek(0, 0)
 
@@ -426,6 +431,9 @@ func GuessSeparator(file *os.File) (rune, error) {
 		// read up to the first 100 lines.
 		// Flags Error if the number of fields is different form first line or if EOF
 		for i := 1; i <= 100; i++ {
+			if err := ctx.Err(); err != nil {
+				return ',', fmt.Errorf("stopped at line %d: %w", i, err)
+			}
 
 			_, err := r.Read()
 			if err != nil {
@@ -473,50 +481,38 @@ func mapHeadingToCol(colNames []string) (colMap map[string]int) {
 	return colMap
 }
 
-func getIntCols(colNames []string) (intCols []string) {
-	for _, colName := range colNames {
+func getIntCols(colNames []string) (intCols []headingCol) {
+	for colIx, colName := range colNames {
 		if colName != "" {
 			i, err := strconv.Atoi(colName)
 			if err == nil && i != 0 {
-				intCols = append(intCols, strconv.Itoa(i))
+				intCols = append(intCols, headingCol{heading: strconv.Itoa(i), colIx: colIx})
 			}
 		}
 	}
 	return intCols
 }
 
-func getMeltCols(colNames []string, colMap map[string]int, definedCols []string, ignoreHdgs []string, hasIntCols bool, intColHdgs []string) []string {
-	var mappedCols []string
-	var meltCols []string
+// getMeltCols returns every column which is not used by a field, an intcols column or ignored
+func getMeltCols(colNames []string, usedCols map[int]bool, ignoreHdgs []string) []headingCol {
+	var meltCols []headingCol
 
-	for _, colName := range colNames {
-		for mappedCol := range colMap {
-			mappedCols = append(mappedCols, mappedCol)
-		}
+	for colIx, colName := range colNames {
 		// check if column heading is empty
 		heading := colName
 		if heading == "" {
 			continue
 		}
-		_, isMapped := find(mappedCols, heading)
-		if isMapped {
-			continue
-		}
-		_, isDefined := find(definedCols, heading)
-		if isDefined {
+		if usedCols[colIx] {
 			continue
 		}
 		_, isIgnored := find(ignoreHdgs, heading)
 		if isIgnored {
 			continue
 		}
-		_, isIntCol := find(intColHdgs, heading)
-		if isIntCol && hasIntCols {
-			continue
-		}
 
 		// if we've not exited the iteration by now, we are a melt column
-		meltCols = append(meltCols, heading)
+		meltCols = append(meltCols, headingCol{heading: heading, colIx: colIx})
 	}
 	return meltCols
 }
@@ -542,99 +538,68 @@ func GetDbFields(model interface{}) ([]string, error) {
 // takes the text string of a CSV field and converts it to a reflect.Value of a given type (supplied as a reflect.Type)
 // used internally, but exposed as it may have uses elsewhere
 func StringToType(input string, outType reflect.Type, params Params) reflect.Value {
+	result, err := ConvertString(input, outType, params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return result
+}
+
+// ConvertString does the same as StringToType, but returns an error rather than
+// stopping the program if the text cannot be converted
+func ConvertString(input string, outType reflect.Type, params Params) (reflect.Value, error) {
 	switch outType.Kind() {
 	case reflect.String:
 		rtnString := strings.ToValidUTF8(input, "")
-		return reflect.ValueOf(rtnString)
+		return reflect.ValueOf(rtnString).Convert(outType), nil
 	case reflect.Bool:
-		if strings.ContainsAny(input[0:2], "YyTt1") || strings.Contains(strings.ToLower(input), "true") || strings.Contains(strings.ToLower(input), "yes") {
-			return reflect.ValueOf(true)
-		} else {
-			return reflect.ValueOf(false)
-		}
+		return convertBool(input, outType, params)
 	case reflect.Int, reflect.Uint, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8:
-		result := reflect.New(reflect.Type(outType))
-
-		i, err := strconv.Atoi(input)
-		if err != nil {
-			log.Fatal("stringToType could not convert "+input+" to integer: ", err)
-		} else {
-			if outType.Kind() == reflect.Int || outType.Kind() == reflect.Int64 || outType.Kind() == reflect.Int32 || outType.Kind() == reflect.Int16 || outType.Kind() == reflect.Int8 {
-				result.Elem().SetInt(int64(i))
-				return result.Elem()
-			} else {
-				result.Elem().SetUint(uint64(i))
-				return result.Elem()
-			}
-		}
+		return convertInt(input, outType, params)
 	case reflect.Float32, reflect.Float64:
-		resultPtr := reflect.New(reflect.Type(outType))
-		var bitSize int
-		if outType.Kind() == reflect.Float64 {
-			bitSize = 64
-		} else {
-			bitSize = 32
+		return convertFloat(input, outType, params)
+	case reflect.Slice, reflect.Array:
+		if !reflect.PtrTo(outType).Implements(textUnmarshalerType) {
+			return convertList(input, outType, params)
 		}
-		if strings.Contains(input, "inf") {
-
-			if strings.Contains(input, "-") {
-				if outType.Kind() == reflect.Float64 {
-					f := -math.MaxFloat64
-					resultPtr.Elem().SetFloat(f)
-				} else {
-					f := -math.MaxFloat32
-					resultPtr.Elem().SetFloat(f)
-				}
-			} else {
-				if outType.Kind() == reflect.Float64 {
-					f := math.MaxFloat64
-					resultPtr.Elem().SetFloat(f)
-				} else {
-					f := math.MaxFloat32
-					resultPtr.Elem().SetFloat(f)
-				}
+		fallthrough
+	case reflect.Struct:
+		switch outType {
+		case decimalType:
+			decimal, err := ParseDecimalSep(input, params.DecimalSep)
+			if err != nil {
+				return reflect.Zero(outType), fmt.Errorf("stringToType could not convert %q to %s: %w", input, outType, err)
 			}
-			return resultPtr.Elem()
-		}
-
-		f, err := strconv.ParseFloat(input, bitSize)
-		if err != nil {
-			//fmt.Print("failed first")
-			// we could be trying to read a %
-			f, err = strconv.ParseFloat(strings.Replace(input, "%", "", 1), bitSize)
+			return reflect.ValueOf(decimal), nil
+		case moneyType:
+			money, err := ParseMoneySep(input, params.DecimalSep)
 			if err != nil {
-				//fmt.Print("failed first %")
-				// we could be reading a German encoded file with the decimals represented as commas
-				f, err = strconv.ParseFloat(strings.Replace(input, ",", ".", 1), bitSize)
-				if err != nil {
-					//fmt.Print("failed German")
-					// could be German format and %
-					f, err = strconv.ParseFloat(strings.Replace(strings.Replace(input, ",", ".", 1), "%", "", 1), bitSize)
-					if err != nil {
-						//fmt.Print("failed German and %")
-						if params.ErrorOnNaN {
-							log.Fatal("CellToType could not convert "+input+" to float: ", err)
-						}
-						f = math.NaN()
-						resultPtr.Elem().SetFloat(f)
-						return resultPtr.Elem()
-
-					}
-					// number was %, so divide by 100
-					f = f / 100.0
-				}
-				resultPtr.Elem().SetFloat(f)
-				return resultPtr.Elem()
+				return reflect.Zero(outType), fmt.Errorf("stringToType could not convert %q to %s: %w", input, outType, err)
 			}
-			// number was %, so divide by 100
-			f = f / 100.0
+			return reflect.ValueOf(money), nil
 		}
-		resultPtr.Elem().SetFloat(f)
-		return resultPtr.Elem()
-	default:
-		log.Fatal("stringToType has recieved a ", outType, " and does not kow how to handle it")
+		// types which read themselves
+		if reflect.PtrTo(outType).Implements(textUnmarshalerType) {
+			ptr := reflect.New(outType)
+			if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(input)); err != nil {
+				return reflect.Zero(outType), fmt.Errorf("stringToType could not convert %q to %s: %w", input, outType, err)
+			}
+			return ptr.Elem(), nil
+		}
+	case reflect.Ptr:
+		// pointers, which can be NULL, are left nil for an empty cell
+		if strings.TrimSpace(input) == "" {
+			return reflect.Zero(outType), nil
+		}
+		value, err := ConvertString(input, outType.Elem(), params)
+		if err != nil {
+			return reflect.Zero(outType), err
+		}
+		ptr := reflect.New(outType.Elem())
+		ptr.Elem().Set(value)
+		return ptr, nil
 	}
-	return reflect.ValueOf(errors.New("stringToType could not convert type " + outType.Name()))
+	return reflect.Zero(outType), errors.New("stringToType has recieved a " + outType.String() + " and does not kow how to handle it")
 }
 
 func ExcelColIdToColNo(colID string) (int, error) {
@@ -776,11 +741,36 @@ func find(slice []string, val string) (int, bool) {
 	return -1, false
 }
 
+// rowErrors collects the errors found while reading a file, so that all of them can be reported at once
+type rowErrors []error
+
+func (errs rowErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (errs rowErrors) Unwrap() []error {
+	return errs
+}
+
+// appendErr adds err to the errors collected in errs
+func appendErr(errs error, err error) error {
+	if errs == nil {
+		return rowErrors{err}
+	}
+	if collected, ok := errs.(rowErrors); ok {
+		return append(collected, err)
+	}
+	return rowErrors{errs, err}
+}
+
 // configurations that need implimenting
 // -------------------------------------
 // * which separator character the encoding uses
 // * which decimal format is used
-// * whether % needs to divide the number by 100 or not
 
 // routines which need implimenting
 // -------------------------------------
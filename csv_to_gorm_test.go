@@ -0,0 +1,95 @@
+package csv_to_gorm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tempCSV writes the content to a file which is removed at the end of the test, and opens it for reading
+func tempCSV(t testing.TB, content string) *os.File {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "test.csv")
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+type testFruit struct {
+	Name     string  `xtg:"col:Name"`
+	Diameter float64 `xtg:"col:Diameter"`
+	Found    int     `xtg:"col:Found"`
+	Organic  bool    `xtg:"col:Organic"`
+}
+
+func TestCsvToSlice(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []testFruit
+		errs    []string // parts of the error expected, in order
+	}{
+		{
+			name:    "all rows convert",
+			content: "Name;Diameter;Found;Organic\nCox;6.5;1825;true\nGala;7;1934;no\n",
+			want:    []testFruit{{"Cox", 6.5, 1825, true}, {"Gala", 7, 1934, false}},
+		},
+		{
+			name:    "rows with errors are left out",
+			content: "Name;Diameter;Found;Organic\nCox;6.5;1825;true\nGala;7;nineteen;no\nFuji;7.5;1939;yes\n",
+			want:    []testFruit{{"Cox", 6.5, 1825, true}, {"Fuji", 7.5, 1939, true}},
+			errs:    []string{"row 3: field Found"},
+		},
+		{
+			name:    "rows with too few cells are errors",
+			content: "Name;Diameter;Found;Organic\nCox;6.5\nGala;7;1934;no\n",
+			want:    []testFruit{{"Gala", 7, 1934, false}},
+			errs:    []string{"row 2: ", "wrong number of fields"},
+		},
+		{
+			name:    "columns in any order",
+			content: "Organic;Found;Name;Diameter\nyes;1825;Cox;6.5\n",
+			want:    []testFruit{{"Cox", 6.5, 1825, true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CsvToSlice(tempCSV(t, tt.content), ';', &testFruit{}, Params{})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			checkErrs(t, err, tt.errs)
+		})
+	}
+}
+
+// checkErrs checks that err mentions each of the parts in order, or is nil if there are none
+func checkErrs(t *testing.T, err error, parts []string) {
+	t.Helper()
+	if len(parts) == 0 {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("no error, want one mentioning %q", parts)
+	}
+	msg := err.Error()
+	for _, part := range parts {
+		ix := strings.Index(msg, part)
+		if ix < 0 {
+			t.Errorf("error %q does not mention %q", err, part)
+			return
+		}
+		msg = msg[ix+len(part):]
+	}
+}
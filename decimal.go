@@ -0,0 +1,187 @@
+package csv_to_gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, for amounts which float64 would round, such as money.  The zero value is 0.
+// Cells may use a decimal point or a decimal comma, with the other, spaces or apostrophes grouping thousands,
+// e.g. 1,234.50, 1.234,50 or 1 234,50.  A single comma or point followed by three digits, as in 1,234, could
+// be either, so is an error unless the DecimalSep of the params says which it is.
+// It is stored as text, in a decimal column
+type Decimal struct {
+	unscaled *big.Int // the digits without the point, nil for 0
+	scale    int32    // number of digits after the point
+}
+
+var decimalType = reflect.TypeOf(Decimal{})
+
+// DecimalSeparator says whether Decimal and Money cells have a decimal point or a decimal comma
+type DecimalSeparator int
+
+const (
+	EitherSeparator DecimalSeparator = iota // worked out from each cell, the last of a point and a comma being the decimal one
+	DecimalPoint                            // 1,234.50, with commas grouping thousands
+	DecimalComma                            // 1.234,50, with points grouping thousands
+)
+
+var (
+	decimalPattern = regexp.MustCompile(`^([+-]?)(\d*)(?:\.(\d*))?(?:[eE]([+-]?\d+))?$`)
+	// a single separator which may be grouping thousands, as in 1,234 or -12.500
+	ambiguousPattern = regexp.MustCompile(`^[+-]?[1-9]\d{0,2}[.,]\d{3}$`)
+)
+
+// maxDecimalDigits is the most digits, before or after the point, a Decimal is read with, so that a cell
+// such as 1e2000000000 cannot take all the memory there is
+const maxDecimalDigits = 1 << 16
+
+// ParseDecimal reads a decimal number, such as -1234.50, 1.234,50 or 1.5e3, working out the decimal separator.
+// Numbers such as 1,234, whose separator could be either, are errors
+func ParseDecimal(s string) (Decimal, error) {
+	return ParseDecimalSep(s, EitherSeparator)
+}
+
+// ParseDecimalSep reads a decimal number with the decimal separator given
+func ParseDecimalSep(s string, sep DecimalSeparator) (Decimal, error) {
+	text := strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(strings.TrimSpace(s))
+	dot, comma := strings.LastIndex(text, "."), strings.LastIndex(text, ",")
+	if sep == DecimalPoint && dot >= 0 && comma > dot || sep == DecimalComma && comma >= 0 && dot > comma {
+		return Decimal{}, fmt.Errorf("%q has thousands grouped after the decimal separator", s)
+	}
+	switch {
+	case sep == DecimalPoint:
+		text = strings.ReplaceAll(text, ",", "")
+	case sep == DecimalComma:
+		text = strings.Replace(strings.ReplaceAll(text, ".", ""), ",", ".", 1)
+	case ambiguousPattern.MatchString(text):
+		return Decimal{}, fmt.Errorf("%q could have a decimal point or a decimal comma", s)
+	case dot >= 0 && comma >= 0:
+		// the last separator is the decimal one
+		if comma > dot {
+			text = strings.Replace(strings.ReplaceAll(text, ".", ""), ",", ".", 1)
+		} else {
+			text = strings.ReplaceAll(text, ",", "")
+		}
+	case comma >= 0:
+		if strings.Count(text, ",") > 1 {
+			text = strings.ReplaceAll(text, ",", "")
+		} else {
+			text = strings.Replace(text, ",", ".", 1)
+		}
+	case strings.Count(text, ".") > 1:
+		text = strings.ReplaceAll(text, ".", "")
+	}
+
+	match := decimalPattern.FindStringSubmatch(text)
+	if match == nil || match[2]+match[3] == "" {
+		return Decimal{}, fmt.Errorf("%q is not a decimal number", s)
+	}
+	unscaled, _ := new(big.Int).SetString(match[2]+match[3], 10)
+	scale := int64(len(match[3]))
+	if match[4] != "" {
+		exponent, err := strconv.ParseInt(match[4], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("%q is not a decimal number: %w", s, err)
+		}
+		scale -= exponent
+	}
+	if scale > maxDecimalDigits {
+		return Decimal{}, fmt.Errorf("%q has too many decimal places", s)
+	}
+	if scale < 0 {
+		// the digits are checked before they are made
+		if int64(len(match[2]+match[3]))-scale > maxDecimalDigits {
+			return Decimal{}, fmt.Errorf("%q has too many digits", s)
+		}
+		unscaled.Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(-scale), nil))
+		scale = 0
+	}
+	if match[1] == "-" {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// String gives the number with a decimal point and no grouping, keeping the decimal places it was given with
+func (d Decimal) String() string {
+	if d.unscaled == nil {
+		return "0"
+	}
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if d.scale > 0 {
+		if len(digits) <= int(d.scale) {
+			digits = strings.Repeat("0", int(d.scale)-len(digits)+1) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rat returns the number as a big.Rat, for arithmetic
+func (d Decimal) Rat() *big.Rat {
+	if d.unscaled == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).SetFrac(d.unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil))
+}
+
+// Float64 returns the nearest float64, which may not be exact
+func (d Decimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+// UnmarshalText lets JSON and YAML read a Decimal, with a decimal point as String gives it
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimalSep(string(text), DecimalPoint)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalText writes the Decimal as String does
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Value stores the Decimal as text, which the database converts to its decimal type
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan reads a Decimal from the database.  NULL is read as 0; use a *Decimal field to keep it
+func (d *Decimal) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case string:
+		return d.UnmarshalText([]byte(src))
+	case []byte:
+		return d.UnmarshalText(src)
+	case int64:
+		*d = Decimal{unscaled: big.NewInt(src)}
+		return nil
+	case float64:
+		// databases without a decimal type, such as sqlite, may give back a float
+		return d.UnmarshalText([]byte(strconv.FormatFloat(src, 'f', -1, 64)))
+	}
+	return fmt.Errorf("cannot scan %T into a Decimal", src)
+}
+
+// GormDataType makes gorm migrate Decimal fields to decimal columns
+func (Decimal) GormDataType() string {
+	return "decimal"
+}
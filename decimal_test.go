@@ -0,0 +1,204 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // as String gives it, empty if the input is not a decimal
+	}{
+		{"0", "0"},
+		{"-1234.50", "-1234.50"},
+		{"+12", "12"},
+		{".5", "0.5"},
+		{"1,234.50", "1234.50"},
+		{"1.234,50", "1234.50"},
+		{"1 234,50", "1234.50"},
+		{"1'234'567", "1234567"},
+		{"1,234,567", "1234567"},
+		{"1.234.567", "1234567"},
+		{"12,5", "12.5"},
+		{"1.5e3", "1500"},
+		{"1.5E-3", "0.0015"},
+		{"-2e2", "-200"},
+		{"0.1", "0.1"},
+		{"0,125", "0.125"},
+		{"1,2345", "1.2345"},
+		{"1234,567", "1234.567"},
+		{"1,234", ""},
+		{"1.234", ""},
+		{"-12.500", ""},
+		{"", ""},
+		{"abc", ""},
+		{"1.2.3,4,5", ""},
+		{"1e", ""},
+		{"1e99999999999", ""},
+		{"1e2000000000", ""},
+		{"1e-2000000000", ""},
+		{"1e70000", ""},
+		{"1e-70000", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.input)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q gave %s, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.input, err)
+		} else if got.String() != tt.want {
+			t.Errorf("%q gave %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDecimalSep(t *testing.T) {
+	tests := []struct {
+		input string
+		point string // read with a decimal point, empty if an error
+		comma string // read with a decimal comma, empty if an error
+	}{
+		{"1,234", "1234", "1.234"},
+		{"1.234", "1.234", "1234"},
+		{"1,234.50", "1234.50", ""},
+		{"1.234,50", "", "1234.50"},
+		{"1 234", "1234", "1234"},
+		{"1,234,567", "1234567", ""},
+		{"1.234.567", "", "1234567"},
+		{"-12", "-12", "-12"},
+	}
+	for _, tt := range tests {
+		for _, sep := range []struct {
+			sep  DecimalSeparator
+			want string
+		}{{DecimalPoint, tt.point}, {DecimalComma, tt.comma}} {
+			got, err := ParseDecimalSep(tt.input, sep.sep)
+			switch {
+			case sep.want == "" && err == nil:
+				t.Errorf("%q with separator %d gave %s, want an error", tt.input, sep.sep, got)
+			case sep.want != "" && err != nil:
+				t.Errorf("%q with separator %d: %v", tt.input, sep.sep, err)
+			case sep.want != "" && got.String() != sep.want:
+				t.Errorf("%q with separator %d gave %s, want %s", tt.input, sep.sep, got, sep.want)
+			}
+		}
+	}
+}
+
+func TestDecimalExact(t *testing.T) {
+	// 0.1 + 0.2 is 0.3 exactly, as float64 does not give it
+	a, _ := ParseDecimal("0.1")
+	b, _ := ParseDecimal("0.2")
+	sum := a.Rat()
+	sum.Add(sum, b.Rat())
+	if sum.RatString() != "3/10" {
+		t.Errorf("0.1 + 0.2 is %s", sum.RatString())
+	}
+
+	scans := []struct {
+		src  interface{}
+		want string
+	}{
+		{"12.50", "12.50"},
+		{[]byte("12.50"), "12.50"},
+		{"1.234", "1.234"},
+		{12.5, "12.5"},
+		{int64(12), "12"},
+		{nil, "0"},
+	}
+	for _, tt := range scans {
+		var scanned Decimal
+		if err := scanned.Scan(tt.src); err != nil {
+			t.Errorf("scanning %v: %v", tt.src, err)
+		} else if scanned.String() != tt.want {
+			t.Errorf("scanning %v gave %s, want %s", tt.src, scanned, tt.want)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		amount   string
+		currency string
+		err      bool
+	}{
+		{input: "€1.234,50", amount: "1234.50", currency: "EUR"},
+		{input: "USD 12.00", amount: "12.00", currency: "USD"},
+		{input: "12.00 eur", amount: "12.00", currency: "EUR"},
+		{input: "-£5", amount: "-5", currency: "GBP"},
+		{input: "US$3", amount: "3", currency: "USD"},
+		{input: "R$ 10,5", amount: "10.5", currency: "BRL"},
+		{input: "42", amount: "42"},
+		{input: "$1,234", err: true},
+		{input: "USD 1,234", err: true},
+		{input: "1.234 EUR", err: true},
+		{input: "€", err: true},
+		{input: "ten euros", err: true},
+		{input: "€1e2000000000", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q gave %s, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.input, err)
+		} else if got.Amount.String() != tt.amount || got.Currency != tt.currency {
+			t.Errorf("%q gave %s %s, want %s %s", tt.input, got.Amount, got.Currency, tt.amount, tt.currency)
+		}
+	}
+}
+
+// Decimal and Money fields are read from cells through ConvertString
+func TestConvertDecimalMoney(t *testing.T) {
+	value, err := ConvertString("1.234,5", reflect.TypeOf(Decimal{}), Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := value.Interface().(Decimal); d.String() != "1234.5" {
+		t.Errorf("Decimal read as %s", d)
+	}
+
+	value, err = ConvertString("CHF 7.05", reflect.TypeOf(&Money{}), Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := value.Interface().(*Money); m.String() != "7.05 CHF" {
+		t.Errorf("Money read as %s", m)
+	}
+
+	// a lone separator before three digits needs the DecimalSep of the params
+	if _, err := ConvertString("$1,234", reflect.TypeOf(Money{}), Params{}); err == nil {
+		t.Error("Money read from $1,234 without a DecimalSep")
+	}
+	seps := []struct {
+		input string
+		sep   DecimalSeparator
+		want  string
+	}{
+		{"$1,234", DecimalPoint, "1234 USD"},
+		{"1.234 EUR", DecimalComma, "1234 EUR"},
+		{"€1.234", DecimalPoint, "1.234 EUR"},
+	}
+	for _, tt := range seps {
+		value, err := ConvertString(tt.input, reflect.TypeOf(Money{}), Params{DecimalSep: tt.sep})
+		if err != nil {
+			t.Errorf("%q: %v", tt.input, err)
+		} else if m := value.Interface().(Money); m.String() != tt.want {
+			t.Errorf("%q read as %s, want %s", tt.input, m, tt.want)
+		}
+	}
+
+	if _, err := ConvertString("lots", reflect.TypeOf(Money{}), Params{}); err == nil {
+		t.Error("Money read from lots")
+	}
+}
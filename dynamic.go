@@ -0,0 +1,157 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// CsvToMaps converts the content of a CSV file to a slice of maps, one per row, for when there is no go
+// model to fill.  The maps are keyed by the Name of the columns in cols and hold values of the column's Kind.
+// cols would normally come from InferColumns, and may be edited to change types or leave columns out.
+// Empty cells become nil, so are stored as NULL by gorm.  Rows with cells which cannot be converted are
+// left out, and their errors returned with the maps of the other rows
+func CsvToMaps(file *os.File, colSep rune, cols []ColumnInfo, params Params) ([]map[string]interface{}, error) {
+	return CsvToMapsContext(context.Background(), file, colSep, cols, params)
+}
+
+// CsvToMapsContext is CsvToMaps, stopping with the context's error, and the rows read so far,
+// if the context is cancelled before the end of the file
+func CsvToMapsContext(ctx context.Context, file *os.File, colSep rune, cols []ColumnInfo, params Params) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	var errs error
+	_, err := readMaps(ctx, file, colSep, cols, params, nil, func(row map[string]interface{}, rowErrs []error) error {
+		for _, err := range rowErrs {
+			errs = appendErr(errs, err)
+		}
+		if len(rowErrs) == 0 {
+			rows = append(rows, row)
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, errs
+}
+
+// readMaps reads the rows of the file as maps, passing each to emit with the errors of its cells, if any.
+// Rows the csv reader cannot parse are passed with their error and no map.  filtered counts the rows left out
+// by the RowFilter or RecordFilter of the params, which are not passed to emit.  Only problems which stop the
+// whole file being read, an error from emit or the cancellation of ctx are returned.
+// The content of the file is written to tee, if not nil, as it is read
+func readMaps(ctx context.Context, file *os.File, colSep rune, cols []ColumnInfo, params Params, tee io.Writer,
+	emit func(row map[string]interface{}, errs []error) error) (filtered int, err error) {
+
+	// make sure we start at the start of the file
+	file.Seek(0, 0)
+
+	colTypes := make([]reflect.Type, len(cols))
+	keys := make([]string, len(cols))
+	for ix, col := range cols {
+		keys[ix] = col.Name
+		if keys[ix] == "" {
+			keys[ix] = col.Heading
+		}
+		typ, ok := TypeByName(col.Kind.String())
+		if !ok {
+			return 0, fmt.Errorf("column %s has kind %s, which cannot be read from a CSV file", col.Heading, col.Kind)
+		}
+		colTypes[ix] = typ
+	}
+
+	progress, counted := newProgressReporter(file, params, tee)
+	r := csv.NewReader(counted)
+	r.Comma = colSep
+	r.FieldsPerRecord = -1
+
+	var headingCols map[string]int
+	rowIx := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return filtered, fmt.Errorf("stopped after row %d: %w", rowIx, err)
+		}
+		csvRecord, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		rowIx++
+		if err != nil {
+			if !isParseError(err) {
+				// the file itself cannot be read, so reading on would give the same error
+				return filtered, fmt.Errorf("row %d: %w", rowIx, err)
+			}
+			if err := emit(nil, []error{fmt.Errorf("row %d: %w", rowIx, err)}); err != nil {
+				return filtered, err
+			}
+			progress.row(0)
+			continue
+		}
+		if rowIx == 1 {
+			if err := checkCols(cols, csvRecord, !params.FirstRowHasData); err != nil {
+				return filtered, err
+			}
+		}
+		if rowIx == 1 && !params.FirstRowHasData {
+			headingCols = mapHeadingToCol(csvRecord)
+			progress.row(0)
+			continue
+		}
+		if !params.keepRow(rowIx, csvRecord, headingCols) {
+			filtered++
+			progress.row(0)
+			continue
+		}
+
+		var rowErrs []error
+		row := make(map[string]interface{}, len(cols))
+		for ix, col := range cols {
+			if col.ColNo < 1 || col.ColNo > len(csvRecord) || strings.TrimSpace(csvRecord[col.ColNo-1]) == "" {
+				row[keys[ix]] = nil
+				continue
+			}
+			value, err := ConvertString(csvRecord[col.ColNo-1], colTypes[ix], params)
+			if err != nil {
+				rowErrs = append(rowErrs, fmt.Errorf("row %d: column %s: %w", rowIx, col.Heading, err))
+				row[keys[ix]] = nil
+				continue
+			}
+			row[keys[ix]] = value.Interface()
+		}
+		if len(rowErrs) == 0 && params.RecordFilter != nil && !params.RecordFilter(row) {
+			filtered++
+			progress.row(0)
+			continue
+		}
+		if err := emit(row, rowErrs); err != nil {
+			return filtered, err
+		}
+		if len(rowErrs) > 0 {
+			progress.row(0)
+		} else {
+			progress.row(1)
+		}
+	}
+	params.logger().Debug("reached end of input file", "rows", progress.RowsRead, "records", progress.Records)
+	progress.done()
+	return filtered, nil
+}
+
+// checkCols makes sure the first row of the file has each of the columns, under its heading if hasHeadings,
+// as a column which is not there would be read as NULL in every row
+func checkCols(cols []ColumnInfo, firstRow []string, hasHeadings bool) error {
+	var errs error
+	for _, col := range cols {
+		switch {
+		case col.ColNo < 1 || col.ColNo > len(firstRow):
+			errs = appendErr(errs, fmt.Errorf("column %s is number %d, which the file does not have", col.Heading, col.ColNo))
+		case hasHeadings && strings.TrimSpace(firstRow[col.ColNo-1]) != strings.TrimSpace(col.Heading):
+			errs = appendErr(errs, fmt.Errorf("column %d is headed %s, not %s", col.ColNo, firstRow[col.ColNo-1], col.Heading))
+		}
+	}
+	return errs
+}
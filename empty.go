@@ -0,0 +1,63 @@
+package csv_to_gorm
+
+import (
+	"strings"
+)
+
+// EmptyValues says what happens to a record made from an empty intcols or melt value cell
+type EmptyValues int
+
+const (
+	ConvertEmpty EmptyValues = iota // the cell is converted like any other, so may be an error, 0 or NaN
+	SkipEmpty                       // the record is left out and counted as skipped
+	NullEmpty                       // the value field is left unset, which is NULL for a pointer field
+)
+
+// isEmpty says whether a cell holds nothing, being blank or one of the NullTokens of the params
+func (params Params) isEmpty(cell string) bool {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return true
+	}
+	_, isNull := find(params.NullTokens, cell)
+	return isNull
+}
+
+// isValue says whether a field takes the value cell of an intcols or melt column
+func (f *fieldPlan) isValue() bool {
+	return f.role == roleIntColsValue || f.role == roleMeltValue
+}
+
+// valueCell returns the cell a value field is read from.  ok is false if there is none, as for a melt group
+// without a column for the member.  Rows too short to reach the column have an empty cell
+func (f *fieldPlan) valueCell(csvRecord []string, intCol headingCol, meltCol headingCol) (cell string, ok bool) {
+	colIx := intCol.colIx
+	if f.role == roleMeltValue {
+		colIx = meltCol.colIx
+		if meltCol.groupCols != nil {
+			if colIx, ok = meltCol.groupCols[f.tag.MeltGroup]; !ok {
+				return "", false
+			}
+		}
+	}
+	if colIx < 0 || colIx >= len(csvRecord) {
+		return "", true
+	}
+	return csvRecord[colIx], true
+}
+
+// allValuesEmpty says whether every value cell of a record is empty, for models which drop such records
+func (fp *filePlan) allValuesEmpty(csvRecord []string, intCol headingCol, meltCol headingCol, params Params) bool {
+	values := 0
+	for ix := range fp.fields {
+		f := &fp.fields[ix]
+		if !f.isValue() || fp.srcCols[ix] >= 0 {
+			continue
+		}
+		values++
+		if cell, ok := f.valueCell(csvRecord, intCol, meltCol); ok && !params.isEmpty(cell) {
+			return false
+		}
+	}
+	return values > 0
+}
@@ -0,0 +1,128 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testHarvest struct {
+	Name  string `xtg:"col:Name"`
+	Year  int    `xtg:"intcols:colname"`
+	Boxes *int   `xtg:"intcols:value"`
+}
+
+type testPestDrop struct {
+	Variety string   `xtg:"col:Name,dropempty"`
+	Pest    string   `xtg:"melt:cost:colname"`
+	Cost    *float64 `xtg:"melt:cost:value,meltcols:prefix:Cost "`
+	Loss    *float64 `xtg:"melt:loss:value,meltcols:prefix:Loss "`
+}
+
+func TestEmptyValues(t *testing.T) {
+	content := "Name;2020;2021\nCox;1;2\nGala;3; \nFuji;NA;4\n"
+	boxes := func(n int) *int { return &n }
+	cox := []testHarvest{{"Cox", 2020, boxes(1)}, {"Cox", 2021, boxes(2)}}
+	// converted, a blank cell is nil for a pointer field, but NA is only empty when skipped or left unset
+	tests := []struct {
+		name   string
+		params Params
+		want   []testHarvest
+		errs   []string
+	}{
+		{
+			name:   "convert",
+			params: Params{EmptyValues: ConvertEmpty},
+			want:   append(cox, testHarvest{"Gala", 2020, boxes(3)}, testHarvest{"Gala", 2021, nil}),
+			errs:   []string{"row 4: field Boxes", "NA"},
+		},
+		{
+			name:   "convert with null tokens",
+			params: Params{EmptyValues: ConvertEmpty, NullTokens: []string{"NA"}},
+			want:   append(cox, testHarvest{"Gala", 2020, boxes(3)}, testHarvest{"Gala", 2021, nil}),
+			errs:   []string{"row 4: field Boxes", "NA"},
+		},
+		{
+			name:   "skip",
+			params: Params{EmptyValues: SkipEmpty},
+			want:   append(cox, testHarvest{"Gala", 2020, boxes(3)}),
+			errs:   []string{"row 4: field Boxes"},
+		},
+		{
+			name:   "skip with null tokens",
+			params: Params{EmptyValues: SkipEmpty, NullTokens: []string{"NA", "-"}},
+			want:   append(cox, testHarvest{"Gala", 2020, boxes(3)}, testHarvest{"Fuji", 2021, boxes(4)}),
+		},
+		{
+			name:   "null with null tokens",
+			params: Params{EmptyValues: NullEmpty, NullTokens: []string{"NA"}},
+			want: append(cox, testHarvest{"Gala", 2020, boxes(3)}, testHarvest{"Gala", 2021, nil},
+				testHarvest{"Fuji", 2020, nil}, testHarvest{"Fuji", 2021, boxes(4)}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CsvToSlice(tempCSV(t, content), ';', &testHarvest{}, tt.params)
+			checkErrs(t, err, tt.errs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v,\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// records left out for an empty cell are counted as skipped
+func TestEmptyValuesSkipped(t *testing.T) {
+	db := testDB(t)
+	params := ImportParams{Migrate: true, Params: Params{EmptyValues: SkipEmpty}}
+	result, err := Import(db, tempCSV(t, "Name;2020;2021\nCox;1;\nGala;;\n"), ';', &testHarvest{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 2, Inserted: 1, Skipped: 3}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+}
+
+// dropempty leaves out the members whose cells are empty in every group, but keeps those with some values
+func TestDropEmpty(t *testing.T) {
+	content := "Name;Cost aphid;Cost mite;Cost wasp;Loss aphid;Loss mite;Loss wasp\nCox;1;;;;NA;2\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testPestDrop{}, Params{EmptyValues: NullEmpty, NullTokens: []string{"NA"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, two := 1.0, 2.0
+	want := []testPestDrop{{"Cox", "aphid", &one, nil}, {"Cox", "wasp", nil, &two}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+}
+
+type testGradedHarvest struct {
+	Name  string `xtg:"col:Name"`
+	Grade int    `xtg:"col:Grade"`
+	Year  int    `xtg:"intcols:colname"`
+	Boxes int    `xtg:"intcols:value"`
+}
+
+// SkipEmpty leaves out records with an empty cell, but not those of a member some melt group has no column for.
+// The other cells of a record left out are not converted, so cannot reject the row
+func TestSkipEmpty(t *testing.T) {
+	params := Params{EmptyValues: SkipEmpty}
+	content := "Name;Cost aphid;Cost mite;Cost wasp;Loss mite;Loss wasp\nCox;1;2;3;20;\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testPestPrefix{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testPestPrefix{{"Cox", "aphid", 1, 0}, {"Cox", "mite", 2, 20}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	content = "Name;Grade;2020;2021\nCox;1;5;6\nGala;first;;\nFuji;second;7;\n"
+	got, err = CsvToSlice(tempCSV(t, content), ';', &testGradedHarvest{}, params)
+	checkErrs(t, err, []string{"row 4: field Grade"})
+	wantGraded := []testGradedHarvest{{"Cox", 1, 2020, 5}, {"Cox", 1, 2021, 6}}
+	if !reflect.DeepEqual(got, wantGraded) {
+		t.Errorf("got %+v, want %+v", got, wantGraded)
+	}
+}
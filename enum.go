@@ -0,0 +1,96 @@
+package csv_to_gorm
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	enumsMu sync.RWMutex
+	enums   = make(map[reflect.Type]map[string]reflect.Value)
+)
+
+// RegisterEnum makes the constants of an integer type with a String method known by name, so that cells and
+// value maps can give the name rather than the number, e.g. RegisterEnum(Winter, Cooking, Eating)
+func RegisterEnum(values ...fmt.Stringer) {
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+	for _, value := range values {
+		typ := reflect.TypeOf(value)
+		if enums[typ] == nil {
+			enums[typ] = make(map[string]reflect.Value)
+		}
+		enums[typ][value.String()] = reflect.ValueOf(value)
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// enumValue converts the name of a constant to an integer type registered with RegisterEnum, or one which
+// implements encoding.TextUnmarshaler.  ok is false if the type is neither
+func enumValue(name string, outType reflect.Type) (value reflect.Value, ok bool, err error) {
+	enumsMu.RLock()
+	names, registered := enums[outType]
+	enumsMu.RUnlock()
+	if registered {
+		if value, found := names[name]; found {
+			return value.Convert(outType), true, nil
+		}
+		known := make([]string, 0, len(names))
+		for constant := range names {
+			known = append(known, constant)
+		}
+		sort.Strings(known)
+		return reflect.Zero(outType), true, fmt.Errorf("%q is not a %s, which may be %s", name, outType, strings.Join(known, ", "))
+	}
+	if reflect.PtrTo(outType).Implements(textUnmarshalerType) {
+		ptr := reflect.New(outType)
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(name)); err != nil {
+			return reflect.Zero(outType), true, err
+		}
+		return ptr.Elem(), true, nil
+	}
+	return reflect.Zero(outType), false, nil
+}
+
+// mapValue replaces the cell with its entry in the value map of the field, given by the tag, the mapping or
+// the ValueMaps of the params, the last taking precedence.  mapped says whether it was replaced.  Cells not in
+// the map are errors, apart from empty cells of pointer fields, which are left as they are to become nil
+func (f *fieldPlan) mapValue(cell string, params Params) (value string, mapped bool, err error) {
+	values := f.values
+	if byParams, ok := params.ValueMaps[f.field.Name]; ok {
+		values = byParams
+	}
+	if values == nil {
+		return cell, false, nil
+	}
+	if value, ok := values[cell]; ok {
+		return value, true, nil
+	}
+	if strings.TrimSpace(cell) == "" && f.field.Type.Kind() == reflect.Ptr {
+		return cell, false, nil
+	}
+	known := make([]string, 0, len(values))
+	for key := range values {
+		known = append(known, key)
+	}
+	sort.Strings(known)
+	return cell, false, fmt.Errorf("%q is not in the value map, which has %s", cell, strings.Join(known, ", "))
+}
+
+// parseValueMap reads the part of a values: sub-tag after the colon, in the form <cell>=<value>;<cell>=<value>
+func parseValueMap(spec string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(spec, ";") {
+		ix := strings.Index(entry, "=")
+		if ix < 0 {
+			return nil, fmt.Errorf("value map entry %q should be in the form <cell>=<value>", entry)
+		}
+		values[entry[:ix]] = entry[ix+1:]
+	}
+	return values, nil
+}
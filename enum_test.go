@@ -0,0 +1,119 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testUse int
+
+const (
+	testWinter testUse = iota
+	testCooking
+	testEating
+)
+
+func (u testUse) String() string { return [...]string{"Winter", "Cooking", "Eating"}[u] }
+
+// testRipeness reads its own names, as a type implementing encoding.TextUnmarshaler
+type testRipeness int
+
+func (r *testRipeness) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "green":
+		*r = 1
+	case "ripe":
+		*r = 2
+	default:
+		return errors.New("unknown ripeness " + string(text))
+	}
+	return nil
+}
+
+type testCoded struct {
+	Name     string       `xtg:"col:Name"`
+	Organic  bool         `xtg:"col:Bio,values:J=true;Ja=true;N=false;Nein=false"`
+	Use      testUse      `xtg:"col:Use,values:W=Winter;C=Cooking;E=Eating"`
+	Colour   *string      `xtg:"col:Colour,values:r=red;g=green"`
+	Ripeness testRipeness `xtg:"col:Ripeness"`
+}
+
+func TestValueMaps(t *testing.T) {
+	RegisterEnum(testWinter, testCooking, testEating)
+	red, green := "red", "green"
+	tests := []struct {
+		name    string
+		content string
+		params  Params
+		want    []testCoded
+		errs    []string
+	}{
+		{
+			name:    "codes",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;J;E;r;ripe\nBramley;Nein;C;;green\n",
+			want:    []testCoded{{"Cox", true, testEating, &red, 2}, {"Bramley", false, testCooking, nil, 1}},
+		},
+		{
+			name:    "cells not in the map",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;yes;E;r;ripe\nGala;J;Eating;g;ripe\nFuji;J;E;b;ripe\nBramley;J;E;g;rotten\n",
+			errs: []string{
+				`row 2: field Organic: "yes" is not in the value map, which has J, Ja, N, Nein`,
+				`row 3: field Use: "Eating" is not in the value map`,
+				`row 4: field Colour: "b" is not in the value map, which has g, r`,
+				"row 5: field Ripeness", "unknown ripeness rotten",
+			},
+		},
+		{
+			name:    "value maps of the params",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;ja;Eating;g;ripe\n",
+			params: Params{ValueMaps: map[string]map[string]string{
+				"Organic": {"ja": "true", "nein": "false"},
+				"Use":     {"Winter": "Winter", "Cooking": "Cooking", "Eating": "Eating"},
+			}},
+			want: []testCoded{{"Cox", true, testEating, &green, 2}},
+		},
+		{
+			name:    "bools mapped whatever the vocabulary",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;J;E;r;ripe\nBramley;N;C;g;green\n",
+			params:  Params{Bools: []BoolWords{GermanBools}},
+			want:    []testCoded{{"Cox", true, testEating, &red, 2}, {"Bramley", false, testCooking, &green, 1}},
+		},
+		{
+			name:    "bools mapped to words",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;J;E;r;ripe\n",
+			params:  Params{Bools: []BoolWords{GermanBools}, ValueMaps: map[string]map[string]string{"Organic": {"J": "ja"}}},
+			errs:    []string{`row 2: field Organic: stringToType could not convert "ja" to a bool`},
+		},
+		{
+			name:    "names not registered",
+			content: "Name;Bio;Use;Colour;Ripeness\nCox;J;E;g;ripe\n",
+			params:  Params{ValueMaps: map[string]map[string]string{"Use": {"E": "Dessert"}}},
+			errs:    []string{`row 2: field Use: "Dessert" is not a csv_to_gorm.testUse, which may be Cooking, Eating, Winter`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CsvToSlice(tempCSV(t, tt.content), ';', &testCoded{}, tt.params)
+			checkErrs(t, err, tt.errs)
+			if len(tt.want) == 0 {
+				if reflect.ValueOf(got).Len() != 0 {
+					t.Errorf("got %+v, want no records", got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v,\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValueMap(t *testing.T) {
+	got, err := parseValueMap("J=true;N=false;=false")
+	want := map[string]string{"J": "true", "N": "false", "": "false"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+	if _, err := parseValueMap("J=true;N"); err == nil {
+		t.Error("an entry without = did not fail")
+	}
+}
@@ -0,0 +1,48 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+)
+
+// Row is a row of the file as given to the RowFilter of the params, before its cells are converted
+type Row struct {
+	Number int // row number in the file, starting at 1 with the heading row
+	Cells  []string
+	cols   map[string]int // column number, starting at 1, by heading
+}
+
+// Get returns the cell under the heading, or "" if the file has no such column or the row does not reach it.
+// Files whose first row has data have no headings
+func (r Row) Get(heading string) string {
+	colNo := r.cols[heading]
+	if colNo < 1 || colNo > len(r.Cells) {
+		return ""
+	}
+	return r.Cells[colNo-1]
+}
+
+// Has says whether the file has a column with the heading
+func (r Row) Has(heading string) bool {
+	return r.cols[heading] > 0
+}
+
+// keepRow applies the RowFilter of the params
+func (params Params) keepRow(rowNo int, csvRecord []string, cols map[string]int) bool {
+	return params.RowFilter == nil || params.RowFilter(Row{Number: rowNo, Cells: csvRecord, cols: cols})
+}
+
+// keepRecords applies the RecordFilter of the params, returning the records kept and the number left out
+func (params Params) keepRecords(records []reflect.Value) (kept []reflect.Value, filtered int) {
+	if params.RecordFilter == nil {
+		return records, 0
+	}
+	kept = records[:0]
+	for _, record := range records {
+		if params.RecordFilter(record.Interface()) {
+			kept = append(kept, record)
+		} else {
+			filtered++
+		}
+	}
+	return kept, filtered
+}
@@ -0,0 +1,105 @@
+package csv_to_gorm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRowGet(t *testing.T) {
+	row := Row{Number: 2, Cells: []string{"Cox", "6.5"}, cols: map[string]int{"Name": 1, "Diameter": 2, "Found": 3}}
+	tests := []struct {
+		heading string
+		has     bool
+		want    string
+	}{
+		{heading: "Name", has: true, want: "Cox"},
+		{heading: "Diameter", has: true, want: "6.5"},
+		{heading: "Found", has: true, want: ""}, // the row does not reach the column
+		{heading: "Organic", has: false, want: ""},
+		{heading: "name", has: false, want: ""},
+	}
+	for _, tt := range tests {
+		if has, got := row.Has(tt.heading), row.Get(tt.heading); has != tt.has || got != tt.want {
+			t.Errorf("%s: has %v, got %q, want %v, %q", tt.heading, has, got, tt.has, tt.want)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	content := "Name;Diameter;Found;Organic\nCox;6.5;1825;true\nGala;7;1934;no\nFuji;7.5;x;yes\nBramley;9;1809;no\n"
+	notGala := func(row Row) bool { return row.Get("Name") != "Gala" }
+	organic := func(record interface{}) bool { return record.(testFruit).Organic }
+	tests := []struct {
+		name   string
+		params Params
+		want   ImportResult
+		names  []string
+	}{
+		{
+			name:   "none",
+			params: Params{},
+			want:   ImportResult{RowsRead: 4, Inserted: 3, Rejected: 1},
+			names:  []string{"Bramley", "Cox", "Gala"},
+		},
+		{
+			name:   "rows",
+			params: Params{RowFilter: notGala},
+			want:   ImportResult{RowsRead: 4, Inserted: 2, Rejected: 1, Filtered: 1},
+			names:  []string{"Bramley", "Cox"},
+		},
+		{
+			name:   "records",
+			params: Params{RecordFilter: organic},
+			want:   ImportResult{RowsRead: 4, Inserted: 1, Rejected: 1, Filtered: 2},
+			names:  []string{"Cox"},
+		},
+		{
+			name:   "rows and records",
+			params: Params{RowFilter: notGala, RecordFilter: organic},
+			want:   ImportResult{RowsRead: 4, Inserted: 1, Rejected: 1, Filtered: 2},
+			names:  []string{"Cox"},
+		},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{0, 4} {
+			t.Run(fmt.Sprintf("%s/workers=%d", tt.name, workers), func(t *testing.T) {
+				db := testDB(t)
+				params := ImportParams{Migrate: true, Params: tt.params}
+				params.Workers = workers
+				result, err := Import(db, tempCSV(t, content), ';', &testFruit{}, params)
+				checkErrs(t, err, []string{"row 4: field Found"})
+				if result != tt.want {
+					t.Errorf("got %+v, want %+v", result, tt.want)
+				}
+				var names []string
+				db.Model(&testFruit{}).Order("name").Pluck("name", &names)
+				if !reflect.DeepEqual(names, tt.names) {
+					t.Errorf("table holds %v, want %v", names, tt.names)
+				}
+			})
+		}
+	}
+}
+
+// ImportTable gives the RecordFilter each row as a map
+func TestImportTableFilters(t *testing.T) {
+	db := testDB(t)
+	params := ImportParams{Migrate: true, Params: Params{
+		RowFilter:    func(row Row) bool { return row.Number != 2 },
+		RecordFilter: func(record interface{}) bool { return record.(map[string]interface{})["product"] == "apple" },
+	}}
+	content := "Product;Name\napple;Cox\napple;Gala\npear;Conference\napple;Fuji\n"
+	result, err := ImportTable(db, "crops", tempCSV(t, content), ';', params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 4, Inserted: 2, Filtered: 2}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	var names []string
+	db.Table("crops").Order("name").Pluck("name", &names)
+	if !reflect.DeepEqual(names, []string{"Fuji", "Gala"}) {
+		t.Errorf("table holds %v", names)
+	}
+}
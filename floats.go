@@ -0,0 +1,126 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Percents says what happens to a float cell ending in %
+type Percents int
+
+const (
+	DividePercent Percents = iota // the number is divided by 100, so 50% is 0.5
+	KeepPercent                   // the % is dropped, so 50% is 50
+	RejectPercent                 // the cell is an error
+)
+
+// Infinities says what an infinite float cell becomes, unless ErrorOnInf makes it an error
+type Infinities int
+
+const (
+	ClampInf Infinities = iota // the largest number the field can hold, for databases which cannot store infinity
+	MathInf                    // math.Inf
+)
+
+// defaultInfTokens are the cells meaning infinity when the params give none.  Any may have a sign
+var defaultInfTokens = []string{"inf", "infinity", "∞"}
+
+// infinite says whether the cell is one of the InfTokens of the params, matched ignoring case
+func (params Params) infinite(cell string) (isInf bool, negative bool) {
+	tokens := params.InfTokens
+	if tokens == nil {
+		tokens = defaultInfTokens
+	}
+	negative = strings.HasPrefix(cell, "-")
+	unsigned := strings.TrimLeft(cell, "+-")
+	for _, token := range tokens {
+		if strings.EqualFold(unsigned, token) {
+			return true, negative
+		}
+	}
+	return false, false
+}
+
+// isNaN says whether the cell is one of the NaNTokens of the params, matched ignoring case
+func (params Params) isNaN(cell string) bool {
+	for _, token := range params.NaNTokens {
+		if strings.EqualFold(cell, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertFloat parses the cell as a float of the kind of outType.  Decimal commas are accepted, as are
+// percentages, infinities and NaN as the params say
+func convertFloat(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+	result := reflect.New(outType).Elem()
+	bitSize := outType.Bits()
+	cell := strings.TrimSpace(input)
+
+	if isInf, negative := params.infinite(cell); isInf {
+		return infinity(input, negative, outType, params)
+	}
+	if params.isNaN(cell) {
+		result.SetFloat(math.NaN())
+		return result, nil
+	}
+
+	percent := strings.Contains(cell, "%")
+	if percent {
+		if params.Percents == RejectPercent {
+			return result, errors.New("stringToType could not convert " + input + " to float: percentages are not allowed")
+		}
+		cell = strings.TrimSpace(strings.Replace(cell, "%", "", 1))
+	}
+	f, err := strconv.ParseFloat(cell, bitSize)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		// we could be reading a German encoded file with the decimals represented as commas
+		f, err = strconv.ParseFloat(strings.Replace(cell, ",", ".", 1), bitSize)
+	}
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		// ParseFloat reads nan, inf and infinity whatever the tokens of the params, which were looked for above
+		err = errors.New("nan and infinity are only read from the NaNTokens and InfTokens")
+	}
+	if errors.Is(err, strconv.ErrRange) && math.IsInf(f, 0) {
+		// too large for the field
+		return infinity(input, f < 0, outType, params)
+	}
+	if err != nil {
+		if params.ErrorOnNaN {
+			return result, fmt.Errorf("CellToType could not convert "+input+" to float: %w", err)
+		}
+		result.SetFloat(math.NaN())
+		return result, nil
+	}
+	if percent && params.Percents == DividePercent {
+		f = f / 100.0
+	}
+	result.SetFloat(f)
+	return result, nil
+}
+
+// infinity gives the value of an infinite cell as the Infinities of the params say
+func infinity(input string, negative bool, outType reflect.Type, params Params) (reflect.Value, error) {
+	result := reflect.New(outType).Elem()
+	if params.ErrorOnInf {
+		return result, errors.New("stringToType could not convert " + input + " to float: infinity is not allowed")
+	}
+	sign := 1.0
+	if negative {
+		sign = -1.0
+	}
+	switch {
+	case params.Infinities == MathInf:
+		result.SetFloat(math.Inf(int(sign)))
+	case outType.Kind() == reflect.Float32:
+		result.SetFloat(sign * math.MaxFloat32)
+	default:
+		result.SetFloat(sign * math.MaxFloat64)
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package csv_to_gorm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvertFloat(t *testing.T) {
+	var (
+		float64Type = reflect.TypeOf(0.0)
+		float32Type = reflect.TypeOf(float32(0))
+		nan         = math.NaN()
+	)
+	tests := []struct {
+		input  string
+		typ    reflect.Type
+		params Params
+		want   float64
+		err    bool
+	}{
+		{input: "6.5", typ: float64Type, want: 6.5},
+		{input: " -6,5 ", typ: float64Type, want: -6.5},
+		{input: "1e3", typ: float64Type, want: 1000},
+		{input: "50%", typ: float64Type, want: 0.5},
+		{input: "12.5 %", typ: float64Type, want: 0.125},
+		{input: "50%", typ: float64Type, params: Params{Percents: KeepPercent}, want: 50},
+		{input: "50%", typ: float64Type, params: Params{Percents: RejectPercent}, err: true},
+		{input: "inf", typ: float64Type, want: math.MaxFloat64},
+		{input: "-Infinity", typ: float64Type, want: -math.MaxFloat64},
+		{input: "∞", typ: float32Type, want: math.MaxFloat32},
+		{input: "1e39", typ: float32Type, want: math.MaxFloat32},
+		{input: "-1e400", typ: float64Type, want: -math.MaxFloat64},
+		{input: "+inf", typ: float64Type, params: Params{Infinities: MathInf}, want: math.Inf(1)},
+		{input: "-inf", typ: float64Type, params: Params{Infinities: MathInf}, want: math.Inf(-1)},
+		{input: "inf", typ: float64Type, params: Params{ErrorOnInf: true}, err: true},
+		{input: "1e400", typ: float64Type, params: Params{ErrorOnInf: true}, err: true},
+		{input: "unbounded", typ: float64Type, params: Params{InfTokens: []string{"unbounded"}}, want: math.MaxFloat64},
+		// spellings ParseFloat reads are not numbers unless they are tokens of the params
+		{input: "inf", typ: float64Type, params: Params{InfTokens: []string{"unbounded"}}, want: nan},
+		{input: "+Inf", typ: float64Type, params: Params{InfTokens: []string{"unbounded"}, ErrorOnNaN: true}, err: true},
+		{input: "Infinity", typ: float64Type, params: Params{InfTokens: []string{"unbounded"}, ErrorOnInf: true}, want: nan},
+		{input: "nan", typ: float64Type, params: Params{ErrorOnNaN: true}, err: true},
+		{input: "NaN", typ: float64Type, params: Params{ErrorOnNaN: true}, err: true},
+		{input: "NaN", typ: float64Type, params: Params{ErrorOnNaN: true, NaNTokens: []string{"nan"}}, want: nan},
+		{input: "many", typ: float64Type, want: nan},
+		{input: "many", typ: float64Type, params: Params{ErrorOnNaN: true}, err: true},
+		{input: "N/A", typ: float64Type, params: Params{ErrorOnNaN: true, NaNTokens: []string{"n/a"}}, want: nan},
+		{input: "nan", typ: float64Type, want: nan},
+	}
+	for _, tt := range tests {
+		value, err := ConvertString(tt.input, tt.typ, tt.params)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q to %s with %+v gave %v, want an error", tt.input, tt.typ, tt.params, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q to %s with %+v: %v", tt.input, tt.typ, tt.params, err)
+			continue
+		}
+		got := value.Float()
+		if got != tt.want && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+			t.Errorf("%q to %s with %+v gave %v, want %v", tt.input, tt.typ, tt.params, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ module github.com/c4rnot/csv_to_gorm
 go 1.16
 
 require (
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/postgres v1.1.0
+	gorm.io/driver/sqlite v1.1.4
 	gorm.io/gorm v1.21.9
 )
@@ -0,0 +1,318 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// ImportParams controls how a file is written to the database
+type ImportParams struct {
+	Params               // how the file is read
+	BatchSize int        // number of records per insert. 1000 if not set
+	Migrate   bool       // create or alter the table to fit the records before importing
+	Mode      ImportMode // what to do with records whose key is already in the table.  InsertOnly if not set
+	Keys      []string   // fields or columns making up the key.  Worked out from the model if not set
+	// Scope picks out the records a ReplaceScope import replaces, e.g.
+	//   func(db *gorm.DB) *gorm.DB { return db.Where("year = ?", 2021) }
+	// If not set, the records with the same mapConst values as the file are replaced
+	Scope func(db *gorm.DB) *gorm.DB
+	// LogImport records the import in the import_logs table (see ImportLog)
+	LogImport bool
+	// OnDuplicate looks in the import log for an earlier successful import of the same content into
+	// the same table and scope.  Setting it logs the import, as if LogImport were set
+	OnDuplicate DuplicateAction
+	ScopeKey    string // names the scope of the import in the import log.  Made from the ConstMap if not set
+	Force       bool   // import even if OnDuplicate finds the file was imported before
+}
+
+func (params ImportParams) batchSize() int {
+	if params.BatchSize < 1 {
+		return 1000
+	}
+	return params.BatchSize
+}
+
+// Import reads a CSV file into the table of the model.  Records are inserted BatchSize at a time as the
+// file is read, rather than the whole file being held in memory.  Rows with cells which cannot be converted
+// are left out, and their errors returned once the rest of the file has been imported.
+// With a Mode other than InsertOnly, records whose key is already in the table are updated or skipped.
+// The import stops if the context of db is cancelled.
+// Returns the number of records inserted, updated and skipped
+func Import(db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams) (ImportResult, error) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ImportContext(ctx, db, file, colSep, model, params)
+}
+
+// ImportContext is Import, with the context used for every database statement.  Cancelling the context stops
+// the import between rows and between batches, returning the context's error together with the row reached
+// and the number of records written by then
+func ImportContext(ctx context.Context, db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams) (ImportResult, error) {
+	if !params.LogImport && params.OnDuplicate == ImportDuplicates {
+		return importModel(ctx, db, file, colSep, model, params, nil)
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return ImportResult{}, err
+	}
+	table := db.Statement.Table
+	if table == "" {
+		table = stmt.Table
+	}
+	return logImport(ctx, db, table, file, &params, func(sum io.Writer) (ImportResult, error) {
+		return importModel(ctx, db, file, colSep, model, params, sum)
+	})
+}
+
+// importModel is ImportContext without the import log.  The content of the file is written to tee as it is read
+func importModel(ctx context.Context, db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams, tee io.Writer) (ImportResult, error) {
+	db = db.WithContext(ctx)
+	modelTyp := reflect.ValueOf(model).Elem().Type()
+
+	plan, err := compilePlan(modelTyp, params.withMapping().Mapping)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return ImportResult{}, err
+	}
+	if name := belongsToAssoc(stmt.Schema, plan); name != "" {
+		// each record would create a parent of its own, even where the rows name the same one
+		return ImportResult{}, errors.New("assoc field " + name + " of " + modelTyp.Name() + " is a belongs-to parent, which Import would create again for every row.  Use ImportGraph, which shares parents between records")
+	}
+
+	if params.Migrate {
+		if err := db.AutoMigrate(model); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	if params.Mode == ReplaceScope {
+		scope := params.Scope
+		if scope == nil {
+			scope, err = constScope(stmt.Schema, plan, params.withMapping())
+			if err != nil {
+				return ImportResult{}, err
+			}
+		}
+		return replaceScope(db, model, scope, params, func(tx *gorm.DB, params ImportParams) (ImportResult, error) {
+			return importModel(ctx, tx, file, colSep, model, params, tee)
+		})
+	}
+
+	w := newBatchWriter(db.Model(model), modelTyp, params)
+	if params.Mode != InsertOnly {
+		keys, hasIndex, err := modelKeys(stmt.Schema, plan, params)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		if params.Migrate && !hasIndex {
+			table := db.Statement.Table
+			if table == "" {
+				table = stmt.Table
+			}
+			if err := createKeyIndex(db, table, keys); err != nil {
+				return ImportResult{}, err
+			}
+		}
+		w.keys = keys
+		w.keyOf = structKeyOf(stmt.Schema, keys)
+	}
+
+	if params.Lookups == nil {
+		params.Lookups = NewLookups(db)
+	}
+
+	var lastRowNo int
+	var errs error
+	err = readRows(ctx, file, colSep, modelTyp, params.Params, tee, func(res rowResult) error {
+		lastRowNo = res.rowNo
+		if res.binding {
+			// a field without a column would be written as its zero value in every record, so nothing is
+			for _, err := range res.errs {
+				errs = appendErr(errs, err)
+			}
+			return errs
+		}
+		w.result.RowsRead++
+		w.result.Skipped += int64(res.skipped)
+		w.result.Filtered += int64(res.filtered)
+		if len(res.errs) > 0 {
+			// the row is rejected
+			w.result.Rejected++
+			for _, err := range res.errs {
+				errs = appendErr(errs, err)
+			}
+			return nil
+		}
+		for _, record := range res.records {
+			if w.batch.Len() == 0 {
+				if err := ctx.Err(); err != nil {
+					return fmt.Errorf("stopped after row %d: %w", lastRowNo, err)
+				}
+			}
+			if err := w.add(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		return w.result, err
+	}
+	return w.result, errs
+}
+
+// ColumnsModel builds a struct type with a field for each column, tagged with the column's Name.
+// gorm can use it to create a table for data read with CsvToMaps
+func ColumnsModel(cols []ColumnInfo) (reflect.Type, error) {
+	headings := make([]string, len(cols))
+	for ix, col := range cols {
+		headings[ix] = col.Heading
+	}
+	fieldNames := FieldNames(headings)
+
+	structFlds := make([]reflect.StructField, len(cols))
+	for ix, col := range cols {
+		typ, ok := TypeByName(col.Kind.String())
+		if !ok {
+			return nil, errors.New("column " + col.Heading + " has kind " + col.Kind.String() + ", which cannot be read from a CSV file")
+		}
+		structFlds[ix] = reflect.StructField{
+			Name: fieldNames[ix],
+			Type: typ,
+			Tag:  reflect.StructTag(`gorm:"column:` + col.Name + `"`),
+		}
+	}
+	return reflect.StructOf(structFlds), nil
+}
+
+// MigrateTable creates the named table, or adds any missing columns to it, so that it can hold the columns
+func MigrateTable(db *gorm.DB, table string, cols []ColumnInfo) error {
+	modelTyp, err := ColumnsModel(cols)
+	if err != nil {
+		return err
+	}
+	return db.Table(table).AutoMigrate(reflect.New(modelTyp).Interface())
+}
+
+// ImportTable reads a CSV file into the named table without needing a go model.
+// The column types are inferred from the content of the file.  With a Mode other than InsertOnly,
+// the Keys of the params say which columns identify a row.
+// Returns the number of rows inserted, updated and skipped
+func ImportTable(db *gorm.DB, table string, file *os.File, colSep rune, params ImportParams) (ImportResult, error) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ImportTableContext(ctx, db, table, file, colSep, params)
+}
+
+// ImportTableContext is ImportTable, with the context used for every database statement.
+// Cancelling the context stops the import between rows and between batches
+func ImportTableContext(ctx context.Context, db *gorm.DB, table string, file *os.File, colSep rune, params ImportParams) (ImportResult, error) {
+	if !params.LogImport && params.OnDuplicate == ImportDuplicates {
+		return importTable(ctx, db, table, file, colSep, params, nil)
+	}
+	return logImport(ctx, db, table, file, &params, func(sum io.Writer) (ImportResult, error) {
+		return importTable(ctx, db, table, file, colSep, params, sum)
+	})
+}
+
+// importTable is ImportTableContext without the import log.  The content of the file is written to tee as it is read
+func importTable(ctx context.Context, db *gorm.DB, table string, file *os.File, colSep rune, params ImportParams, tee io.Writer) (ImportResult, error) {
+	db = db.WithContext(ctx)
+	cols, _, err := InferColumnsContext(ctx, file, colSep, params.Params)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if params.Migrate {
+		if err := MigrateTable(db, table, cols); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	if params.Mode == ReplaceScope {
+		if params.Scope == nil {
+			return ImportResult{}, errors.New("import mode replace needs the Scope of table " + table)
+		}
+		modelTyp, err := ColumnsModel(cols)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		return replaceScope(db.Table(table), reflect.New(modelTyp).Interface(), params.Scope, params,
+			func(tx *gorm.DB, params ImportParams) (ImportResult, error) {
+				return writeMaps(ctx, tx, table, file, colSep, cols, params, tee)
+			})
+	}
+	return writeMaps(ctx, db, table, file, colSep, cols, params, tee)
+}
+
+// writeMaps reads the rows of the file as maps and writes them to the table BatchSize at a time, in the way
+// the Mode asks for.  Rows with cells which cannot be converted are left out, and their errors returned once
+// the rest of the file has been written
+func writeMaps(ctx context.Context, db *gorm.DB, table string, file *os.File, colSep rune, cols []ColumnInfo, params ImportParams, tee io.Writer) (ImportResult, error) {
+	w := newBatchWriter(db.Table(table), reflect.TypeOf(map[string]interface{}{}), params)
+	if params.Mode != InsertOnly {
+		if len(params.Keys) == 0 {
+			return ImportResult{}, errors.New("import mode " + params.Mode.String() + " needs the Keys of table " + table)
+		}
+		keys := make(map[string]bool)
+		for _, key := range params.Keys {
+			keys[key] = true
+		}
+		for _, col := range cols {
+			if !keys[col.Name] {
+				w.updateCols = append(w.updateCols, col.Name)
+			}
+		}
+		if params.Migrate {
+			if err := createKeyIndex(db, table, params.Keys); err != nil {
+				return ImportResult{}, err
+			}
+		}
+		w.keys = params.Keys
+		w.keyOf = mapKeyOf(params.Keys)
+	}
+
+	var errs error
+	filtered, err := readMaps(ctx, file, colSep, cols, params.Params, tee, func(row map[string]interface{}, rowErrs []error) error {
+		w.result.RowsRead++
+		if len(rowErrs) > 0 {
+			// the row is rejected
+			w.result.Rejected++
+			for _, err := range rowErrs {
+				errs = appendErr(errs, err)
+			}
+			return nil
+		}
+		if w.batch.Len() == 0 {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("stopped after %d rows: %w", w.result.RowsRead-1, err)
+			}
+		}
+		return w.add(reflect.ValueOf(row))
+	})
+	// the filtered rows were read, but never reached the writer
+	w.result.RowsRead += int64(filtered)
+	w.result.Filtered = int64(filtered)
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		return w.result, err
+	}
+	return w.result, errs
+}
@@ -0,0 +1,126 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB opens a new sqlite database, removed at the end of the test
+func testDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestImportTable(t *testing.T) {
+	content := "Name;Diameter;Found\nCox;6.5;1825\nGala;7;1934\nFuji;7.5;1939\nBraeburn;7;1952\nJazz;6;1985\n"
+	for _, batchSize := range []int{0, 2} {
+		db := testDB(t)
+		result, err := ImportTable(db, "apples", tempCSV(t, content), ';', ImportParams{BatchSize: batchSize, Migrate: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ImportResult{RowsRead: 5, Inserted: 5}
+		if result != want {
+			t.Errorf("batch size %d: got %+v, want %+v", batchSize, result, want)
+		}
+		var found []int
+		db.Table("apples").Order("found").Pluck("found", &found)
+		if !reflect.DeepEqual(found, []int{1825, 1934, 1939, 1952, 1985}) {
+			t.Errorf("batch size %d: table holds %v", batchSize, found)
+		}
+	}
+}
+
+// rows with cells which do not convert to the columns' types are rejected, and the rest imported
+func TestImportTableRejectsRows(t *testing.T) {
+	file := tempCSV(t, "Name;Diameter;Found\nCox;6.5;1825\nGala;7;unknown\nFuji;7.5;1939\n")
+	cols, _, err := InferColumns(file, ';', Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file says Found is text, but the table holds numbers
+	cols[2].Kind = reflect.Int
+	db := testDB(t)
+	if err := MigrateTable(db, "apples", cols); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := writeMaps(context.Background(), db, "apples", file, ';', cols, ImportParams{}, nil)
+	want := ImportResult{RowsRead: 3, Inserted: 2, Rejected: 1}
+	if result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	checkErrs(t, err, []string{"row 3: column Found"})
+	var names []string
+	db.Table("apples").Order("name").Pluck("name", &names)
+	if !reflect.DeepEqual(names, []string{"Cox", "Fuji"}) {
+		t.Errorf("table holds %v", names)
+	}
+
+	rows, err := CsvToMaps(file, ';', cols, Params{})
+	if len(rows) != 2 || rows[0]["name"] != "Cox" || rows[1]["name"] != "Fuji" {
+		t.Errorf("CsvToMaps gave %v", rows)
+	}
+	checkErrs(t, err, []string{"row 3: column Found"})
+}
+
+// a field without a column would be zero in every record, so nothing is written, nor deleted
+func TestImportMissingHeading(t *testing.T) {
+	content := "Name;Diameter;Found\nCox;6.5;1825\nGala;7;1934\n"
+	for _, mode := range []ImportMode{InsertOnly, ReplaceScope} {
+		db := testDB(t)
+		db.AutoMigrate(&testFruit{})
+		db.Create(&testFruit{Name: "Bramley", Diameter: 9, Found: 1809})
+		params := ImportParams{Mode: mode, Scope: func(db *gorm.DB) *gorm.DB { return db.Where("found > 0") }}
+		result, err := Import(db, tempCSV(t, content), ';', &testFruit{}, params)
+		checkErrs(t, err, []string{"Could not find column header Organic"})
+		if result != (ImportResult{}) {
+			t.Errorf("%s: got %+v, want nothing imported", mode, result)
+		}
+		var count int64
+		db.Model(&testFruit{}).Count(&count)
+		if count != 1 {
+			t.Errorf("%s: table holds %d records, want only the one there before", mode, count)
+		}
+	}
+}
+
+// CsvToMaps and ImportTable check the columns given are in the file, under their headings
+func TestMapsMissingColumn(t *testing.T) {
+	content := "Name;Diameter\nCox;6.5\n"
+	tests := []struct {
+		name string
+		cols []ColumnInfo
+		errs []string
+	}{
+		{
+			name: "beyond the last column",
+			cols: []ColumnInfo{{Heading: "Name", ColNo: 1, Kind: reflect.String}, {Heading: "Found", ColNo: 3, Kind: reflect.Int}},
+			errs: []string{"column Found is number 3, which the file does not have"},
+		},
+		{
+			name: "under another heading",
+			cols: []ColumnInfo{{Heading: "Name", ColNo: 1, Kind: reflect.String}, {Heading: "Found", ColNo: 2, Kind: reflect.Int}},
+			errs: []string{"column 2 is headed Diameter, not Found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := CsvToMaps(tempCSV(t, content), ';', tt.cols, Params{})
+			checkErrs(t, err, tt.errs)
+			if len(rows) != 0 {
+				t.Errorf("read %v", rows)
+			}
+		})
+	}
+}
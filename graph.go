@@ -0,0 +1,317 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ImportGraph reads a CSV file into a model together with the models of its assoc fields, such as a Country
+// with a slice of Exporters made from the same row.  Unlike Import, the whole file is read before anything is
+// written, so that records repeated over several rows can be merged: records of the model with the same key
+// (see the key sub-tag) become one record holding the assoc records of all of them, and belongs-to parents
+// with the same key, or the same values if they have no key, are created once and shared.
+// Everything is written in one transaction, parents first, then the records of the model, then their children.
+// Only InsertOnly is supported
+func ImportGraph(db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams) (ImportResult, error) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ImportGraphContext(ctx, db, file, colSep, model, params)
+}
+
+// ImportGraphContext is ImportGraph, with the context used for every database statement
+func ImportGraphContext(ctx context.Context, db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams) (ImportResult, error) {
+	if params.Mode != InsertOnly {
+		return ImportResult{}, errors.New("ImportGraph only supports import mode insert, not " + params.Mode.String())
+	}
+	if !params.LogImport && params.OnDuplicate == ImportDuplicates {
+		return importGraph(ctx, db, file, colSep, model, params, nil)
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return ImportResult{}, err
+	}
+	table := db.Statement.Table
+	if table == "" {
+		table = stmt.Table
+	}
+	return logImport(ctx, db, table, file, &params, func(sum io.Writer) (ImportResult, error) {
+		return importGraph(ctx, db, file, colSep, model, params, sum)
+	})
+}
+
+// graphParent is a belongs-to assoc field of the model, whose parents are shared between records
+type graphParent struct {
+	field *schema.Field
+	plan  *modelPlan
+	keys  map[string]int // key of a parent to its index in parents
+	// parents holds one of each parent, which the records point to once they have been created
+	parents reflect.Value
+}
+
+// belongsToAssoc returns the name of the first assoc field of the model which is a belongs-to parent, if any
+func belongsToAssoc(sch *schema.Schema, plan *modelPlan) string {
+	for _, f := range plan.fields {
+		if rel, ok := sch.Relationships.Relations[f.field.Name]; f.role == roleAssoc && ok && rel.Type == schema.BelongsTo {
+			return f.field.Name
+		}
+	}
+	return ""
+}
+
+func importGraph(ctx context.Context, db *gorm.DB, file *os.File, colSep rune, model interface{}, params ImportParams, tee io.Writer) (ImportResult, error) {
+	db = db.WithContext(ctx)
+	modelTyp := reflect.ValueOf(model).Elem().Type()
+	params.Params = params.withMapping()
+
+	plan, err := compilePlan(modelTyp, params.Mapping)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return ImportResult{}, err
+	}
+	if params.Migrate {
+		if err := db.AutoMigrate(graphModels(modelTyp, nil)...); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	if params.Lookups == nil {
+		params.Lookups = NewLookups(db)
+	}
+
+	var parents []*graphParent
+	for _, f := range plan.fields {
+		rel, ok := stmt.Schema.Relationships.Relations[f.field.Name]
+		if f.role != roleAssoc || !ok || rel.Type != schema.BelongsTo {
+			continue
+		}
+		parentTyp := assocType(f.field.Type)
+		parentPlan, err := compilePlan(parentTyp, nil)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		parents = append(parents, &graphParent{
+			field:   stmt.Schema.LookUpField(f.field.Name),
+			plan:    parentPlan,
+			keys:    make(map[string]int),
+			parents: reflect.MakeSlice(reflect.SliceOf(parentTyp), 0, 0),
+		})
+	}
+
+	// read the whole file, merging records with the same key
+	var result ImportResult
+	var errs error
+	records := reflect.MakeSlice(reflect.SliceOf(modelTyp), 0, 0)
+	recordKeys := make(map[string]int)
+	err = readRows(ctx, file, colSep, modelTyp, params.Params, tee, func(res rowResult) error {
+		if res.binding {
+			// as for Import, nothing is written if a field has no column
+			for _, err := range res.errs {
+				errs = appendErr(errs, err)
+			}
+			return errs
+		}
+		result.RowsRead++
+		result.Skipped += int64(res.skipped)
+		result.Filtered += int64(res.filtered)
+		if len(res.errs) > 0 {
+			result.Rejected++
+			for _, err := range res.errs {
+				errs = appendErr(errs, err)
+			}
+			return nil
+		}
+		for _, record := range res.records {
+			key, hasKey := plan.recordKey(record)
+			if ix, ok := recordKeys[key]; hasKey && ok {
+				plan.merge(records.Index(ix), record)
+				continue
+			}
+			if hasKey {
+				recordKeys[key] = records.Len()
+			}
+			records = reflect.Append(records, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// parents first, once each, so that records sharing a parent get the same one
+		for _, p := range parents {
+			for ix := 0; ix < records.Len(); ix++ {
+				p.share(records.Index(ix))
+			}
+			if p.parents.Len() == 0 {
+				continue
+			}
+			created, err := createInBatches(tx, p.parents, params.batchSize())
+			result.Associated += created
+			if err != nil {
+				return err
+			}
+			for ix := 0; ix < records.Len(); ix++ {
+				p.point(records.Index(ix))
+			}
+		}
+
+		for ix := 0; ix < records.Len(); ix++ {
+			result.Associated += plan.countChildren(records.Index(ix), stmt.Schema)
+		}
+		created, err := createInBatches(tx.Model(model), records, params.batchSize())
+		result.Inserted += created
+		return err
+	})
+	if err != nil {
+		return ImportResult{RowsRead: result.RowsRead, Rejected: result.Rejected}, err
+	}
+	return result, errs
+}
+
+// createInBatches inserts the records batchSize at a time, returning how many were inserted
+func createInBatches(db *gorm.DB, records reflect.Value, batchSize int) (int64, error) {
+	var created int64
+	for start := 0; start < records.Len(); start += batchSize {
+		end := start + batchSize
+		if end > records.Len() {
+			end = records.Len()
+		}
+		batch := reflect.New(records.Type())
+		batch.Elem().Set(records.Slice(start, end))
+		result := db.Create(batch.Interface())
+		created += result.RowsAffected
+		if result.Error != nil {
+			return created, result.Error
+		}
+	}
+	return created, nil
+}
+
+// graphModels lists the model and the models of its assoc fields, for migrating
+func graphModels(typ reflect.Type, seen map[reflect.Type]bool) []interface{} {
+	if seen == nil {
+		seen = make(map[reflect.Type]bool)
+	}
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	models := []interface{}{reflect.New(typ).Interface()}
+	plan, err := compilePlan(typ, nil)
+	if err != nil {
+		return models
+	}
+	for _, f := range plan.fields {
+		if f.role == roleAssoc {
+			models = append(models, graphModels(assocType(f.field.Type), seen)...)
+		}
+	}
+	return models
+}
+
+// recordKey returns the values of the key fields of a record.  hasKey is false if the model has no key fields
+func (plan *modelPlan) recordKey(record reflect.Value) (key string, hasKey bool) {
+	var values []interface{}
+	for _, f := range plan.fields {
+		if f.tag.IsKey {
+			values = append(values, record.Field(f.index).Interface())
+		}
+	}
+	return keyString(values), len(values) > 0
+}
+
+// identity returns the key of a record, or if the model has no key fields, the values of all the fields
+// read from the file, so that records made from the same cells are seen as the same
+func (plan *modelPlan) identity(record reflect.Value) string {
+	if key, hasKey := plan.recordKey(record); hasKey {
+		return key
+	}
+	var values []interface{}
+	for _, f := range plan.fields {
+		switch f.role {
+		case roleNone, roleMeta, roleAssoc:
+			continue
+		}
+		values = append(values, record.Field(f.index).Interface())
+	}
+	return keyString(values)
+}
+
+// merge adds the slice assoc records of from to into, which has the same key
+func (plan *modelPlan) merge(into reflect.Value, from reflect.Value) {
+	for _, f := range plan.fields {
+		if f.role != roleAssoc || f.field.Type.Kind() != reflect.Slice {
+			continue
+		}
+		field := into.Field(f.index)
+		field.Set(reflect.AppendSlice(field, from.Field(f.index)))
+	}
+}
+
+// countChildren counts the has-one and has-many records held by a record
+func (plan *modelPlan) countChildren(record reflect.Value, sch *schema.Schema) int64 {
+	var count int64
+	for _, f := range plan.fields {
+		rel, ok := sch.Relationships.Relations[f.field.Name]
+		if f.role != roleAssoc || !ok || rel.Type == schema.BelongsTo {
+			continue
+		}
+		field := record.Field(f.index)
+		switch field.Kind() {
+		case reflect.Slice:
+			count += int64(field.Len())
+		case reflect.Ptr:
+			if !field.IsNil() {
+				count++
+			}
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// parentOf returns the parent held by the record, or an invalid value if it has none
+func (p *graphParent) parentOf(record reflect.Value) reflect.Value {
+	parent := record.FieldByIndex(p.field.StructField.Index)
+	if parent.Kind() == reflect.Ptr {
+		if parent.IsNil() {
+			return reflect.Value{}
+		}
+		parent = parent.Elem()
+	}
+	return parent
+}
+
+// share adds the parent of the record to the parents to create, unless an identical one is there already
+func (p *graphParent) share(record reflect.Value) {
+	parent := p.parentOf(record)
+	if !parent.IsValid() {
+		return
+	}
+	key := p.plan.identity(parent)
+	if _, ok := p.keys[key]; !ok {
+		p.keys[key] = p.parents.Len()
+		p.parents = reflect.Append(p.parents, parent)
+	}
+}
+
+// point sets the parent of the record to the created parent with the same identity, which has its ID
+func (p *graphParent) point(record reflect.Value) {
+	parent := p.parentOf(record)
+	if !parent.IsValid() {
+		return
+	}
+	parent.Set(p.parents.Index(p.keys[p.plan.identity(parent)]))
+}
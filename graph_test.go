@@ -0,0 +1,57 @@
+package csv_to_gorm
+
+import "testing"
+
+type testCountry struct {
+	ID       uint
+	Name     string `xtg:"col:country,key"`
+	RegionID uint
+	Region   *testRegion `xtg:"assoc"`
+}
+
+type testRegion struct {
+	ID   uint
+	Name string `xtg:"col:region"`
+}
+
+const testCountries = "country;region\nFrance;Europe\nSpain;Europe\nJapan;Asia\n"
+
+// Import would create a parent for every row, so belongs-to assoc fields are left to ImportGraph
+func TestImportBelongsTo(t *testing.T) {
+	db := testDB(t)
+	_, err := Import(db, tempCSV(t, testCountries), ';', &testCountry{}, ImportParams{Migrate: true})
+	checkErrs(t, err, []string{"assoc field Region", "ImportGraph"})
+	if db.Migrator().HasTable(&testCountry{}) {
+		t.Error("the table was created for an import which could not go ahead")
+	}
+}
+
+// ImportGraph creates the parents named by several rows once
+func TestImportGraphSharesParents(t *testing.T) {
+	db := testDB(t)
+	result, err := ImportGraph(db, tempCSV(t, testCountries), ';', &testCountry{}, ImportParams{Migrate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportResult{RowsRead: 3, Inserted: 3, Associated: 2}
+	if result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	var countries []testCountry
+	if err := db.Preload("Region").Order("id").Find(&countries).Error; err != nil {
+		t.Fatal(err)
+	}
+	regions := make(map[string]string)
+	ids := make(map[uint]bool)
+	for _, country := range countries {
+		if country.Region == nil {
+			t.Fatalf("%s has no region", country.Name)
+		}
+		regions[country.Name] = country.Region.Name
+		ids[country.RegionID] = true
+	}
+	if regions["France"] != "Europe" || regions["Spain"] != "Europe" || regions["Japan"] != "Asia" || len(ids) != 2 {
+		t.Errorf("countries are in regions %v, with %d region IDs", regions, len(ids))
+	}
+}
@@ -0,0 +1,131 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// headingParser picks out the columns intcols fields spread a row over by their headings, and splits each
+// heading into the groups intcols:<group> fields are set to.  Given by a headcols: sub-tag (see the instructions
+// in csv_to_gorm.go).  Without one, intcols takes the non zero integers, as it always has
+type headingParser struct {
+	spec   string
+	groups []string
+	// parse returns what an intcols:colname field is set to and the groups, or ok false if the heading is not a column
+	parse func(heading string) (normal string, groups map[string]string, ok bool)
+}
+
+var (
+	integerHeading = regexp.MustCompile(`^\s*([-+]?\d+)(?:\.0+)?\s*$`)
+	quarterHeading = regexp.MustCompile(`(?i)^\s*(?:(\d{4})\s*[-_/ ]?\s*Q([1-4])|Q([1-4])\s*[-_/ ]?\s*(\d{4}))\s*$`)
+)
+
+// parseHeadCols reads the part of a headcols: sub-tag after the colon
+func parseHeadCols(spec string) (*headingParser, error) {
+	hp := &headingParser{spec: spec}
+	kind, arg := spec, ""
+	if ix := strings.Index(spec, ":"); ix >= 0 {
+		kind, arg = spec[:ix], spec[ix+1:]
+	}
+	switch kind {
+	case "integer":
+		hp.groups = []string{"number"}
+		hp.parse = func(heading string) (string, map[string]string, bool) {
+			match := integerHeading.FindStringSubmatch(heading)
+			if match == nil {
+				return "", nil, false
+			}
+			i, err := strconv.Atoi(match[1])
+			if err != nil || i == 0 {
+				return "", nil, false
+			}
+			normal := strconv.Itoa(i)
+			return normal, map[string]string{"number": normal}, true
+		}
+	case "quarter":
+		hp.groups = []string{"year", "quarter"}
+		hp.parse = func(heading string) (string, map[string]string, bool) {
+			match := quarterHeading.FindStringSubmatch(heading)
+			if match == nil {
+				return "", nil, false
+			}
+			year, quarter := match[1], match[2]
+			if year == "" {
+				year, quarter = match[4], match[3]
+			}
+			return year + "-Q" + quarter, map[string]string{"year": year, "quarter": quarter}, true
+		}
+	case "date":
+		if arg == "" {
+			return nil, errors.New("headcols date needs a layout, e.g. date:Jan 2006")
+		}
+		hp.groups = []string{"year", "month", "day"}
+		hp.parse = func(heading string) (string, map[string]string, bool) {
+			date, err := time.Parse(arg, strings.TrimSpace(heading))
+			if err != nil {
+				return "", nil, false
+			}
+			return date.Format("2006-01-02"), map[string]string{
+				"year":  strconv.Itoa(date.Year()),
+				"month": strconv.Itoa(int(date.Month())),
+				"day":   strconv.Itoa(date.Day()),
+			}, true
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, errors.New("headcols regex is not a valid regular expression: " + err.Error())
+		}
+		names := re.SubexpNames()
+		for ix := 1; ix < len(names); ix++ {
+			hp.groups = append(hp.groups, strconv.Itoa(ix))
+			if names[ix] != "" {
+				hp.groups = append(hp.groups, names[ix])
+			}
+		}
+		hp.parse = func(heading string) (string, map[string]string, bool) {
+			match := re.FindStringSubmatch(heading)
+			if match == nil {
+				return "", nil, false
+			}
+			groups := make(map[string]string, len(match))
+			for ix := 1; ix < len(match); ix++ {
+				groups[strconv.Itoa(ix)] = match[ix]
+				if names[ix] != "" {
+					groups[names[ix]] = match[ix]
+				}
+			}
+			return heading, groups, true
+		}
+	default:
+		return nil, errors.New("headcols must be integer, quarter, date:<layout> or regex:<regexp>, not " + spec)
+	}
+	return hp, nil
+}
+
+// hasGroup says whether the parser splits headings into the named group
+func (hp *headingParser) hasGroup(group string) bool {
+	for _, g := range hp.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// cols returns the columns whose headings the parser accepts
+func (hp *headingParser) cols(colNames []string) (cols []headingCol) {
+	for colIx, colName := range colNames {
+		if colName == "" {
+			continue
+		}
+		normal, groups, ok := hp.parse(colName)
+		if ok {
+			cols = append(cols, headingCol{heading: normal, colIx: colIx, groups: groups})
+		}
+	}
+	return cols
+}
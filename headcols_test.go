@@ -0,0 +1,69 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeadCols(t *testing.T) {
+	tests := []struct {
+		spec    string
+		heading string
+		normal  string
+		groups  map[string]string // nil if the heading is not a column
+	}{
+		{"integer", "2020", "2020", map[string]string{"number": "2020"}},
+		{"integer", " 2020.0 ", "2020", map[string]string{"number": "2020"}},
+		{"integer", "-5", "-5", map[string]string{"number": "-5"}},
+		{"integer", "+7", "7", map[string]string{"number": "7"}},
+		{"integer", "0", "", nil},
+		{"integer", "2020.5", "", nil},
+		{"integer", "Q1", "", nil},
+		{"integer", "Region1", "", nil},
+		{"integer", "FY2021", "", nil},
+		{"integer", "FY2021x", "", nil},
+		{"integer", "", "", nil},
+		{"quarter", "2020-Q1", "2020-Q1", map[string]string{"year": "2020", "quarter": "1"}},
+		{"quarter", "q3 2021", "2021-Q3", map[string]string{"year": "2021", "quarter": "3"}},
+		{"quarter", "2020-Q5", "", nil},
+		{"date:Jan 2006", "Mar 2021", "2021-03-01", map[string]string{"year": "2021", "month": "3", "day": "1"}},
+		{"date:Jan 2006", "March", "", nil},
+		{`regex:^FY(?P<number>\d+)$`, "FY2022", "FY2022", map[string]string{"1": "2022", "number": "2022"}},
+		{`regex:^FY(?P<number>\d+)$`, "Region1", "", nil},
+	}
+	for _, tt := range tests {
+		hp, err := parseHeadCols(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		normal, groups, ok := hp.parse(tt.heading)
+		if ok != (tt.groups != nil) || normal != tt.normal || !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%s of %q gave %q %v %t, want %q %v", tt.spec, tt.heading, normal, groups, ok, tt.normal, tt.groups)
+		}
+	}
+
+	for _, spec := range []string{"date", "regex:(", "fiscal"} {
+		if _, err := parseHeadCols(spec); err == nil {
+			t.Errorf("headcols %s did not fail", spec)
+		}
+	}
+}
+
+type testYield struct {
+	Name  string  `xtg:"col:Name"`
+	Year  int     `xtg:"intcols:number,headcols:integer"`
+	Yield float64 `xtg:"intcols:value"`
+}
+
+// only the columns headed by a year are spread over records, not other headings with numbers in them
+func TestIntColsInteger(t *testing.T) {
+	content := "Name;Region1;2020;2021.0;0;Q1\nCox;North;1.5;2.5;9;9\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testYield{}, Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testYield{{"Cox", 2020, 1.5}, {"Cox", 2021, 2.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImportLog is a row of the import_logs table, written for each import with LogImport set.
+// Together with meta:importid fields, it traces a record back to the file it came from
+type ImportLog struct {
+	ID         uint   `gorm:"primarykey"`
+	ImportID   string `gorm:"uniqueIndex;size:64"`
+	TableName  string // table imported into
+	Scope      string // the ScopeKey of the import params, or its constants if not set
+	FileName   string
+	Sheet      string
+	FileSize   int64
+	FileHash   string // hex encoded SHA-256 of the content of the file, once the whole file has been read or looked for duplicates
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	RowsRead   int64
+	Inserted   int64
+	Updated    int64
+	Skipped    int64
+	Deleted    int64
+	Rejected   int64
+	Filtered   int64
+	Outcome    string // running, succeeded, partial (some rows rejected), duplicate, cancelled or failed
+	Error      string
+}
+
+// import outcomes
+const (
+	OutcomeRunning   = "running"
+	OutcomeSucceeded = "succeeded"
+	OutcomePartial   = "partial"
+	OutcomeDuplicate = "duplicate"
+	OutcomeCancelled = "cancelled"
+	OutcomeFailed    = "failed"
+)
+
+// DuplicateAction says what an import does with a file already imported into the same table and scope
+type DuplicateAction int
+
+const (
+	ImportDuplicates DuplicateAction = iota // the file is imported again.  The import log is not looked at
+	SkipDuplicates                          // the file is left out, and the earlier import named in the ImportResult
+	FailDuplicates                          // the import fails with ErrAlreadyImported
+)
+
+// ErrAlreadyImported is returned, wrapped, by imports with FailDuplicates when the file was imported before
+var ErrAlreadyImported = errors.New("file already imported")
+
+// maxLogError is the longest error kept in the import log
+const maxLogError = 10000
+
+// staleRunning is how long a running import of the same file keeps another from starting.  An entry which has
+// been running longer is taken to be of an import which died without recording how it ended
+const staleRunning = 12 * time.Hour
+
+// logImport records an import in the import log.  run does the import, writing the content of the file to sum.
+// The log is written with a context of its own, so that a cancelled import is still recorded as such
+func logImport(ctx context.Context, db *gorm.DB, table string, file *os.File, params *ImportParams,
+	run func(sum io.Writer) (ImportResult, error)) (ImportResult, error) {
+
+	if params.ImportID == "" {
+		params.ImportID = newImportID()
+	}
+	logDb := db.Session(&gorm.Session{NewDB: true, Context: context.Background()})
+	if err := logDb.AutoMigrate(&ImportLog{}); err != nil {
+		return ImportResult{}, err
+	}
+
+	entry := ImportLog{
+		ImportID:  params.ImportID,
+		TableName: table,
+		Scope:     params.scopeKey(),
+		FileName:  file.Name(),
+		Sheet:     params.Sheet,
+		StartedAt: time.Now(),
+		Outcome:   OutcomeRunning,
+	}
+	if info, err := file.Stat(); err == nil {
+		entry.FileSize = info.Size()
+	}
+
+	checkDuplicates := params.OnDuplicate != ImportDuplicates && !params.Force
+	if checkDuplicates {
+		// the hash is needed before anything is written, so the file is read through once on its own
+		hash, err := hashFile(file)
+		if err != nil {
+			return ImportResult{}, err
+		}
+		entry.FileHash = hash
+	}
+	// the entry is written before looking for duplicates, so that of two imports of the same file started
+	// together, the second finds the first
+	if err := logDb.Create(&entry).Error; err != nil {
+		return ImportResult{}, err
+	}
+
+	if checkDuplicates {
+		var earlier ImportLog
+		found := logDb.Where("table_name = ? AND COALESCE(scope, '') = ? AND file_hash = ? AND id <> ?", table, entry.Scope, entry.FileHash, entry.ID).
+			Where(logDb.Where("outcome = ?", OutcomeSucceeded).
+				Or("outcome = ? AND id < ? AND started_at > ?", OutcomeRunning, entry.ID, entry.StartedAt.Add(-staleRunning))).
+			Order("id DESC").Limit(1).Find(&earlier)
+		if found.Error != nil {
+			return ImportResult{}, found.Error
+		}
+		if found.RowsAffected > 0 {
+			now := time.Now()
+			entry.FinishedAt, entry.Outcome = &now, OutcomeDuplicate
+			entry.Error = "same content as import " + earlier.ImportID
+			if earlier.Outcome == OutcomeRunning {
+				entry.Error += ", which is still running"
+			}
+			if err := logDb.Save(&entry).Error; err != nil {
+				return ImportResult{}, err
+			}
+			result := ImportResult{DuplicateOf: earlier.ImportID}
+			if params.OnDuplicate == FailDuplicates {
+				return result, fmt.Errorf("%w into %s on %s as import %s", ErrAlreadyImported, table, earlier.StartedAt.Format(time.RFC3339), earlier.ImportID)
+			}
+			return result, nil
+		}
+	}
+
+	sum := sha256.New()
+	result, err := run(sum)
+
+	finished := time.Now()
+	entry.FinishedAt = &finished
+	entry.RowsRead, entry.Inserted, entry.Updated = result.RowsRead, result.Inserted, result.Updated
+	entry.Skipped, entry.Deleted, entry.Rejected = result.Skipped, result.Deleted, result.Rejected
+	entry.Filtered = result.Filtered
+	var rowErrs rowErrors
+	switch {
+	case err == nil:
+		entry.Outcome = OutcomeSucceeded
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		entry.Outcome = OutcomeCancelled
+	case errors.As(err, &rowErrs) && params.Mode != ReplaceScope && result.RowsRead > 0:
+		entry.Outcome = OutcomePartial
+	default:
+		entry.Outcome = OutcomeFailed
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if len(entry.Error) > maxLogError {
+			entry.Error = entry.Error[:maxLogError]
+		}
+	}
+	if entry.Outcome == OutcomeSucceeded || entry.Outcome == OutcomePartial {
+		// the whole file was read, so the hash is of all of it
+		entry.FileHash = hex.EncodeToString(sum.Sum(nil))
+	}
+	if logErr := logDb.Save(&entry).Error; logErr != nil && err == nil {
+		err = logErr
+	}
+	return result, err
+}
+
+// scopeKey names the scope of the import in the import log
+func (params ImportParams) scopeKey() string {
+	if params.ScopeKey != "" {
+		return params.ScopeKey
+	}
+	constMap := params.withMapping().ConstMap
+	pairs := make([]string, 0, len(constMap))
+	for key, value := range constMap {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// hashFile returns the hex encoded SHA-256 of the content of the file
+func hashFile(file *os.File) (string, error) {
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	if _, err := io.Copy(sum, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
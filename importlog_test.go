@@ -0,0 +1,117 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImportDuplicates(t *testing.T) {
+	content := "Name;Diameter;Found;Organic\nCox;6.5;1825;true\n"
+	hash, err := hashFile(tempCSV(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		earlier     ImportLog // an entry already in the import log, for the same table and file
+		onDuplicate DuplicateAction
+		force       bool
+		duplicateOf string // empty if the file should be imported
+		outcome     string
+		err         error
+	}{
+		{
+			name:        "first import",
+			onDuplicate: SkipDuplicates,
+			outcome:     OutcomeSucceeded,
+		},
+		{
+			name:        "imported before",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeSucceeded, StartedAt: now.Add(-48 * time.Hour)},
+			onDuplicate: SkipDuplicates,
+			duplicateOf: "earlier",
+			outcome:     OutcomeDuplicate,
+		},
+		{
+			name:        "imported before into another scope",
+			earlier:     ImportLog{ImportID: "earlier", Scope: "year=2020", Outcome: OutcomeSucceeded, StartedAt: now.Add(-time.Hour)},
+			onDuplicate: SkipDuplicates,
+			outcome:     OutcomeSucceeded,
+		},
+		{
+			name:        "failed before",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeFailed, StartedAt: now.Add(-time.Hour)},
+			onDuplicate: SkipDuplicates,
+			outcome:     OutcomeSucceeded,
+		},
+		{
+			name:        "still running",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeRunning, StartedAt: now.Add(-time.Minute)},
+			onDuplicate: SkipDuplicates,
+			duplicateOf: "earlier",
+			outcome:     OutcomeDuplicate,
+		},
+		{
+			name:        "running so long it died",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeRunning, StartedAt: now.Add(-staleRunning - time.Hour)},
+			onDuplicate: SkipDuplicates,
+			outcome:     OutcomeSucceeded,
+		},
+		{
+			name:        "fail on duplicates",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeSucceeded, StartedAt: now.Add(-time.Hour)},
+			onDuplicate: FailDuplicates,
+			duplicateOf: "earlier",
+			outcome:     OutcomeDuplicate,
+			err:         ErrAlreadyImported,
+		},
+		{
+			name:        "forced",
+			earlier:     ImportLog{ImportID: "earlier", Outcome: OutcomeSucceeded, StartedAt: now.Add(-time.Hour)},
+			onDuplicate: SkipDuplicates,
+			force:       true,
+			outcome:     OutcomeSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			if err := db.AutoMigrate(&ImportLog{}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.earlier.ImportID != "" {
+				tt.earlier.TableName, tt.earlier.FileHash = "test_fruits", hash
+				if err := db.Create(&tt.earlier).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			params := ImportParams{Migrate: true, OnDuplicate: tt.onDuplicate, Force: tt.force}
+			result, err := Import(db, tempCSV(t, content), ';', &testFruit{}, params)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if result.DuplicateOf != tt.duplicateOf {
+				t.Errorf("duplicate of %q, want %q", result.DuplicateOf, tt.duplicateOf)
+			}
+			wantInserted := int64(1)
+			if tt.duplicateOf != "" {
+				wantInserted = 0
+			}
+			if result.Inserted != wantInserted {
+				t.Errorf("%d records inserted, want %d", result.Inserted, wantInserted)
+			}
+
+			var entry ImportLog
+			if err := db.Order("id DESC").First(&entry).Error; err != nil {
+				t.Fatal(err)
+			}
+			if entry.Outcome != tt.outcome || entry.FileHash != hash {
+				t.Errorf("logged outcome %s with hash %s, want %s with %s", entry.Outcome, entry.FileHash, tt.outcome, hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package csv_to_gorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm/schema"
+)
+
+// ColumnInfo describes what can be learned about a column of a CSV file by reading its content
+type ColumnInfo struct {
+	Heading    string       // column heading, or the Excel column ID if the first row has data
+	Name       string       // database column name derived from the heading, e.g. liked_by
+	ColNo      int          // column number starting at 1
+	Kind       reflect.Kind // the narrowest kind which all cells convert to
+	EmptyCells int          // number of cells with no content
+	Sample     string       // first non empty cell of the column
+}
+
+// words which are accepted as booleans when inferring column types
+var boolWords = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true,
+	"t": true, "f": true, "y": true, "n": true,
+}
+
+// InferColumns reads the whole file and guesses the type of each column.
+// It also returns the number of data rows (excluding the heading row) in the file
+func InferColumns(file *os.File, colSep rune, params Params) (cols []ColumnInfo, dataRows int, err error) {
+	return InferColumnsContext(context.Background(), file, colSep, params)
+}
+
+// InferColumnsContext is InferColumns, giving up if the context is cancelled
+func InferColumnsContext(ctx context.Context, file *os.File, colSep rune, params Params) (cols []ColumnInfo, dataRows int, err error) {
+	// make sure we start at the start of the file
+	file.Seek(0, 0)
+
+	r := csv.NewReader(file)
+	r.Comma = colSep
+	r.FieldsPerRecord = -1
+
+	// per column flags of whether all cells seen so far can be read as the kind
+	var isInt, isFloat, isBool, isEmpty []bool
+
+	// floats must really be numbers when inferring, rather than NaN
+	floatParams := params
+	floatParams.ErrorOnNaN = true
+	float64Type := reflect.TypeOf(float64(0))
+
+	rowIx := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return cols, dataRows, fmt.Errorf("stopped after row %d: %w", rowIx, err)
+		}
+		csvRecord, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cols, dataRows, fmt.Errorf("could not read row %d: %w", rowIx+1, err)
+		}
+
+		if rowIx == 0 {
+			for colIx, heading := range csvRecord {
+				if params.FirstRowHasData {
+					heading, _ = ExcelColNoToColId(colIx + 1)
+				}
+				cols = append(cols, ColumnInfo{Heading: heading, ColNo: colIx + 1})
+				isInt = append(isInt, true)
+				isFloat = append(isFloat, true)
+				isBool = append(isBool, true)
+				isEmpty = append(isEmpty, true)
+			}
+			rowIx++
+			if !params.FirstRowHasData {
+				continue
+			}
+		} else {
+			rowIx++
+		}
+		dataRows++
+
+		for colIx, cell := range csvRecord {
+			if colIx >= len(cols) {
+				// cells beyond the last heading cannot be mapped to anything
+				break
+			}
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				cols[colIx].EmptyCells++
+				continue
+			}
+			if isEmpty[colIx] {
+				cols[colIx].Sample = cell
+				isEmpty[colIx] = false
+			}
+			if isInt[colIx] {
+				if _, err := strconv.Atoi(cell); err != nil {
+					isInt[colIx] = false
+				}
+			}
+			if isFloat[colIx] && !isInt[colIx] {
+				if _, err := ConvertString(cell, float64Type, floatParams); err != nil {
+					isFloat[colIx] = false
+				}
+			}
+			if isBool[colIx] && !boolWords[strings.ToLower(cell)] {
+				isBool[colIx] = false
+			}
+		}
+	}
+	if rowIx == 0 {
+		return cols, dataRows, errors.New("file is empty")
+	}
+
+	headings := make([]string, len(cols))
+	for colIx, col := range cols {
+		headings[colIx] = col.Heading
+	}
+	for colIx, fieldName := range FieldNames(headings) {
+		cols[colIx].Name = schema.NamingStrategy{}.ColumnName("", fieldName)
+	}
+
+	for colIx := range cols {
+		switch {
+		case isEmpty[colIx]:
+			cols[colIx].Kind = reflect.String
+		case isInt[colIx] && cols[colIx].EmptyCells == 0:
+			cols[colIx].Kind = reflect.Int
+		case isFloat[colIx]:
+			// empty cells become NaN in float fields, so integer columns with gaps are read as floats
+			cols[colIx].Kind = reflect.Float64
+		case isBool[colIx] && cols[colIx].EmptyCells == 0:
+			cols[colIx].Kind = reflect.Bool
+		default:
+			cols[colIx].Kind = reflect.String
+		}
+	}
+	return cols, dataRows, nil
+}
+
+// FieldNames turns column headings into unique, exported Go field names
+// e.g. "Liked By" becomes LikedBy and "2020" becomes Col2020
+func FieldNames(headings []string) []string {
+	names := make([]string, len(headings))
+	used := make(map[string]int)
+
+	for ix, heading := range headings {
+		var name strings.Builder
+		upperNext := true
+		for _, r := range heading {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upperNext = true
+				continue
+			}
+			if upperNext {
+				r = unicode.ToUpper(r)
+				upperNext = false
+			}
+			name.WriteRune(r)
+		}
+		fieldName := name.String()
+		if fieldName == "" {
+			colID, _ := ExcelColNoToColId(ix + 1)
+			fieldName = "Col" + colID
+		} else if !unicode.IsUpper([]rune(fieldName)[0]) {
+			// field names have to start with an upper case letter to be exported
+			fieldName = "Col" + fieldName
+		}
+		used[fieldName]++
+		if used[fieldName] > 1 {
+			fieldName = fieldName + strconv.Itoa(used[fieldName])
+		}
+		names[ix] = fieldName
+	}
+	return names
+}
+
+// GenerateStruct writes the go source of a model struct able to hold the rows of a CSV file
+// described by cols.  The fields are tagged with xtg col: tags so the result can be used by CsvToSlice
+func GenerateStruct(typeName string, cols []ColumnInfo, embedGormModel bool) ([]byte, error) {
+	headings := make([]string, len(cols))
+	for ix, col := range cols {
+		headings[ix] = col.Heading
+	}
+	fieldNames := FieldNames(headings)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "type %s struct {\n", typeName)
+	if embedGormModel {
+		src.WriteString("gorm.Model // include ID, CreatedAt, UpdatedAt, DeletedAt\n")
+	}
+	for ix, col := range cols {
+		if strings.ContainsAny(col.Heading, ",:`\"") {
+			// headings that cannot be written into an xtg tag have to be mapped by column number
+			fmt.Fprintf(&src, "%s %s // column %d: %q\n", fieldNames[ix], col.Kind, col.ColNo, col.Heading)
+			continue
+		}
+		fmt.Fprintf(&src, "%s %s `xtg:\"col:%s\"`\n", fieldNames[ix], col.Kind, col.Heading)
+	}
+	src.WriteString("}\n")
+
+	return format.Source(src.Bytes())
+}
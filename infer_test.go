@@ -0,0 +1,76 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferColumns(t *testing.T) {
+	content := "Name;Diameter;Found;Organic;Liked By;Notes\nCox;6.5;1825;yes;Ann;\nGala;7;1934;no;;old\nFuji;7,5;;y;Bob;\n"
+	cols, dataRows, err := InferColumns(tempCSV(t, content), ';', Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataRows != 3 {
+		t.Errorf("%d data rows, want 3", dataRows)
+	}
+	want := []ColumnInfo{
+		{Heading: "Name", Name: "name", ColNo: 1, Kind: reflect.String, Sample: "Cox"},
+		{Heading: "Diameter", Name: "diameter", ColNo: 2, Kind: reflect.Float64, Sample: "6.5"},
+		{Heading: "Found", Name: "found", ColNo: 3, Kind: reflect.Float64, EmptyCells: 1, Sample: "1825"},
+		{Heading: "Organic", Name: "organic", ColNo: 4, Kind: reflect.Bool, Sample: "yes"},
+		{Heading: "Liked By", Name: "liked_by", ColNo: 5, Kind: reflect.String, EmptyCells: 1, Sample: "Ann"},
+		{Heading: "Notes", Name: "notes", ColNo: 6, Kind: reflect.String, EmptyCells: 2, Sample: "old"},
+	}
+	if !reflect.DeepEqual(cols, want) {
+		t.Errorf("got %+v,\nwant %+v", cols, want)
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	got := FieldNames([]string{"Liked By", "2020", "", "name", "Name", "Größe"})
+	want := []string{"LikedBy", "Col2020", "ColC", "Name", "Name2", "Größe"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGenerateStruct(t *testing.T) {
+	cols := []ColumnInfo{
+		{Heading: "Name", ColNo: 1, Kind: reflect.String},
+		{Heading: "Found", ColNo: 2, Kind: reflect.Int},
+		{Heading: "Cost, €", ColNo: 3, Kind: reflect.Float64},
+	}
+	src, err := GenerateStruct("Apple", cols, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "type Apple struct {\n" +
+		"\tgorm.Model         // include ID, CreatedAt, UpdatedAt, DeletedAt\n" +
+		"\tName       string  `xtg:\"col:Name\"`\n" +
+		"\tFound      int     `xtg:\"col:Found\"`\n" +
+		"\tCost       float64 // column 3: \"Cost, €\"\n" +
+		"}\n"
+	if string(src) != want {
+		t.Errorf("got\n%s\nwant\n%s", src, want)
+	}
+}
+
+func TestGuessSeparator(t *testing.T) {
+	tests := []struct {
+		content string
+		want    rune
+	}{
+		{"Name;Diameter;Found\nCox;6,5;1825\n", ';'},
+		{"Name,Diameter,Found\nCox,6.5,1825\n", ','},
+		{"Name\tDiameter\tFound\nCox\t6.5\t1825\n", '\t'},
+	}
+	for _, tt := range tests {
+		got, err := GuessSeparator(tempCSV(t, tt.content))
+		if err != nil {
+			t.Errorf("%q: %v", tt.content, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
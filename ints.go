@@ -0,0 +1,112 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// convertInt parses the cell as an integer of the kind of outType, failing rather than wrapping round
+// for numbers the type cannot hold.  With IntegralFloats, whole numbers written as floats such as 1960.0
+// or 1e3 are taken, and with IntPrefixes, hexadecimal, octal and binary numbers starting 0x, 0o or 0b
+func convertInt(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+	cell := strings.TrimSpace(input)
+	base := 10
+	if params.IntPrefixes && hasIntPrefix(cell) {
+		base = 0
+	}
+	result := reflect.New(outType).Elem()
+	signed := isSigned(outType)
+
+	var err error
+	if signed {
+		var i int64
+		if i, err = strconv.ParseInt(cell, base, outType.Bits()); err == nil {
+			result.SetInt(i)
+			return result, nil
+		}
+	} else {
+		// ParseUint takes no sign, but ParseInt takes a + so unsigned fields should too
+		var u uint64
+		if u, err = strconv.ParseUint(strings.TrimPrefix(cell, "+"), base, outType.Bits()); err == nil {
+			result.SetUint(u)
+			return result, nil
+		}
+		// ParseUint gives negative numbers a syntax error rather than a range error
+		if strings.HasPrefix(cell, "-") {
+			if i, intErr := strconv.ParseInt(cell, base, 64); intErr == nil && i == 0 {
+				return result, nil
+			} else if intErr == nil || errors.Is(intErr, strconv.ErrRange) {
+				err = strconv.ErrRange
+			}
+		}
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return result, fmt.Errorf("stringToType could not convert %q to %s, which holds %s", input, outType, intBounds(outType))
+	}
+
+	if params.IntegralFloats {
+		if f, floatErr := strconv.ParseFloat(cell, 64); floatErr == nil {
+			if f != math.Trunc(f) {
+				return result, fmt.Errorf("stringToType could not convert %q to %s as it is not a whole number", input, outType)
+			}
+			if !fitsInt(f, outType) {
+				return result, fmt.Errorf("stringToType could not convert %q to %s, which holds %s", input, outType, intBounds(outType))
+			}
+			if signed {
+				result.SetInt(int64(f))
+			} else {
+				result.SetUint(uint64(f))
+			}
+			return result, nil
+		}
+	}
+
+	// the name of a constant of an enum type
+	if value, ok, enumErr := enumValue(input, outType); ok {
+		return value, enumErr
+	}
+	return result, fmt.Errorf("stringToType could not convert "+input+" to integer: %w", err)
+}
+
+// hasIntPrefix says whether the cell, after any sign, starts 0x, 0o or 0b
+func hasIntPrefix(cell string) bool {
+	cell = strings.TrimLeft(cell, "+-")
+	if len(cell) < 2 || cell[0] != '0' {
+		return false
+	}
+	switch cell[1] {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
+}
+
+func isSigned(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8:
+		return true
+	}
+	return false
+}
+
+// fitsInt says whether a whole number, or infinity, is in the range of the integer type
+func fitsInt(f float64, typ reflect.Type) bool {
+	if isSigned(typ) {
+		limit := math.Ldexp(1, typ.Bits()-1)
+		return f >= -limit && f < limit
+	}
+	return f >= 0 && f < math.Ldexp(1, typ.Bits())
+}
+
+// intBounds describes the range of the integer type, e.g. 0 to 255
+func intBounds(typ reflect.Type) string {
+	if isSigned(typ) {
+		bits := uint(typ.Bits())
+		return fmt.Sprintf("%d to %d", int64(-1)<<(bits-1), int64(uint64(1)<<(bits-1)-1))
+	}
+	return fmt.Sprintf("0 to %d", uint64(math.MaxUint64)>>(64-uint(typ.Bits())))
+}
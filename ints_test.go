@@ -0,0 +1,84 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertInt(t *testing.T) {
+	var (
+		intType    = reflect.TypeOf(0)
+		int8Type   = reflect.TypeOf(int8(0))
+		int16Type  = reflect.TypeOf(int16(0))
+		int64Type  = reflect.TypeOf(int64(0))
+		uintType   = reflect.TypeOf(uint(0))
+		uint8Type  = reflect.TypeOf(uint8(0))
+		uint16Type = reflect.TypeOf(uint16(0))
+		uint64Type = reflect.TypeOf(uint64(0))
+		floats     = Params{IntegralFloats: true}
+		prefixes   = Params{IntPrefixes: true}
+	)
+	tests := []struct {
+		input  string
+		typ    reflect.Type
+		params Params
+		want   interface{} // nil if the input should not convert
+	}{
+		{"42", intType, Params{}, 42},
+		{" -42 ", intType, Params{}, -42},
+		{"+7", int8Type, Params{}, int8(7)},
+		{"127", int8Type, Params{}, int8(127)},
+		{"128", int8Type, Params{}, nil},
+		{"-128", int8Type, Params{}, int8(-128)},
+		{"-129", int8Type, Params{}, nil},
+		{"65535", uint16Type, Params{}, uint16(65535)},
+		{"65536", uint16Type, Params{}, nil},
+		{"-1", uintType, Params{}, nil},
+		{"-0", uint8Type, Params{}, uint8(0)},
+		{"+5", uintType, Params{}, uint(5)},
+		{" +255 ", uint8Type, Params{}, uint8(255)},
+		{"+256", uint8Type, Params{}, nil},
+		{"++5", uintType, Params{}, nil},
+		{"18446744073709551615", uint64Type, Params{}, uint64(18446744073709551615)},
+		{"18446744073709551616", uint64Type, Params{}, nil},
+		{"9223372036854775807", int64Type, Params{}, int64(9223372036854775807)},
+		{"9223372036854775808", int64Type, Params{}, nil},
+		{"1960.0", intType, Params{}, nil},
+		{"1960.0", intType, floats, 1960},
+		{"1e3", int16Type, floats, int16(1000)},
+		{"1960.5", intType, floats, nil},
+		{"300.0", uint8Type, floats, nil},
+		{"-1.0", uintType, floats, nil},
+		{"0x1F", intType, Params{}, nil},
+		{"0x1F", intType, prefixes, 31},
+		{"-0x10", int8Type, prefixes, int8(-16)},
+		{"0o17", uintType, prefixes, uint(15)},
+		{"+0x1f", uintType, prefixes, uint(31)},
+		{"0b101", intType, prefixes, 5},
+		{"0x100", uint8Type, prefixes, nil},
+		{"010", intType, prefixes, 10},
+		{"twelve", intType, Params{}, nil},
+	}
+	for _, tt := range tests {
+		value, err := ConvertString(tt.input, tt.typ, tt.params)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q to %s gave %v, want an error", tt.input, tt.typ, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q to %s: %v", tt.input, tt.typ, err)
+		} else if value.Interface() != tt.want {
+			t.Errorf("%q to %s gave %v, want %v", tt.input, tt.typ, value, tt.want)
+		}
+	}
+}
+
+// the error for a number out of range says what the type holds
+func TestConvertIntRangeError(t *testing.T) {
+	_, err := ConvertString("256", reflect.TypeOf(uint8(0)), Params{})
+	checkErrs(t, err, []string{`"256"`, "0 to 255"})
+	_, err = ConvertString("-129", reflect.TypeOf(int8(0)), Params{})
+	checkErrs(t, err, []string{"-128 to 127"})
+}
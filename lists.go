@@ -0,0 +1,259 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// convertList fills a slice or array field from a cell holding a JSON array, such as [1,2,3], or items
+// separated by the ListSep of the params, such as apple;pear;plum.  Each item is converted to the element type.
+// An empty cell is an empty slice or a zero array, and other cells must have as many items as the array holds
+func convertList(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+	cell := strings.TrimSpace(input)
+	if outType.Kind() == reflect.Slice && outType.Elem().Kind() == reflect.Uint8 {
+		// []byte takes the cell as it is
+		return reflect.ValueOf([]byte(input)).Convert(outType), nil
+	}
+
+	var items []string
+	if strings.HasPrefix(cell, "[") && strings.HasSuffix(cell, "]") {
+		items = jsonItems(cell)
+	}
+	if items == nil && cell != "" {
+		sep := params.ListSep
+		if sep == "" {
+			sep = ";"
+		}
+		items = strings.Split(cell, sep)
+		for ix := range items {
+			items[ix] = strings.TrimSpace(items[ix])
+		}
+	}
+
+	var list reflect.Value
+	if outType.Kind() == reflect.Array {
+		list = reflect.New(outType).Elem()
+		if len(items) > 0 && len(items) != outType.Len() {
+			return list, fmt.Errorf("stringToType could not convert %q to %s as it has %d items", input, outType, len(items))
+		}
+	} else {
+		if len(items) == 0 {
+			return reflect.Zero(outType), nil
+		}
+		list = reflect.MakeSlice(outType, len(items), len(items))
+	}
+	for ix, item := range items {
+		value, err := ConvertString(item, outType.Elem(), params)
+		if err != nil {
+			return reflect.Zero(outType), fmt.Errorf("item %d: %w", ix+1, err)
+		}
+		list.Index(ix).Set(value)
+	}
+	return list, nil
+}
+
+// jsonItems returns the items of a JSON array as text, strings unquoted, or nil if the cell is not a JSON array
+func jsonItems(cell string) []string {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(cell), &raw); err != nil {
+		return nil
+	}
+	items := make([]string, len(raw))
+	for ix, item := range raw {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			items[ix] = text
+		} else if string(item) != "null" {
+			items[ix] = string(item)
+		}
+	}
+	return items
+}
+
+// StringArray, IntArray and FloatArray are slice fields gorm can store: as arrays in Postgres and as JSON
+// elsewhere, in json columns in MySQL and text columns otherwise.  Either form can be read back.
+// nil slices are stored as NULL
+type (
+	StringArray []string
+	IntArray    []int64
+	FloatArray  []float64
+)
+
+// GormDataType gives gorm a data type for the fields, which GormDBDataType refines for each database
+func (StringArray) GormDataType() string { return "string" }
+func (IntArray) GormDataType() string    { return "string" }
+func (FloatArray) GormDataType() string  { return "string" }
+
+func (StringArray) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return listDBDataType(db, "text[]")
+}
+
+func (IntArray) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return listDBDataType(db, "bigint[]")
+}
+
+func (FloatArray) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return listDBDataType(db, "double precision[]")
+}
+
+func listDBDataType(db *gorm.DB, postgresType string) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return postgresType
+	case "mysql":
+		return "json"
+	}
+	return "text"
+}
+
+// GormValue writes the slice as a Postgres array literal, such as {"apple","pear"}, or as JSON
+func (a StringArray) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	items := make([]string, len(a))
+	for ix, item := range a {
+		items[ix] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(item) + `"`
+	}
+	return listValue(db, a == nil, []string(a), items)
+}
+
+func (a IntArray) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	items := make([]string, len(a))
+	for ix, item := range a {
+		items[ix] = strconv.FormatInt(item, 10)
+	}
+	return listValue(db, a == nil, []int64(a), items)
+}
+
+func (a FloatArray) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	items := make([]string, len(a))
+	for ix, item := range a {
+		items[ix] = strconv.FormatFloat(item, 'g', -1, 64)
+	}
+	return listValue(db, a == nil, []float64(a), items)
+}
+
+// listValue is the bound value of a list: NULL, a Postgres array literal made from the items, or JSON
+func listValue(db *gorm.DB, isNil bool, list interface{}, items []string) clause.Expr {
+	if isNil {
+		return clause.Expr{SQL: "NULL"}
+	}
+	if db.Dialector.Name() == "postgres" {
+		return clause.Expr{SQL: "?", Vars: []interface{}{"{" + strings.Join(items, ",") + "}"}}
+	}
+	text, err := json.Marshal(list)
+	if err != nil {
+		db.AddError(err)
+	}
+	return clause.Expr{SQL: "?", Vars: []interface{}{string(text)}}
+}
+
+// Scan reads a Postgres array or JSON
+func (a *StringArray) Scan(src interface{}) error {
+	items, err := scanList(src)
+	*a = items
+	return err
+}
+
+func (a *IntArray) Scan(src interface{}) error {
+	items, err := scanList(src)
+	if err != nil || items == nil {
+		*a = nil
+		return err
+	}
+	*a = make(IntArray, len(items))
+	for ix, item := range items {
+		if (*a)[ix], err = strconv.ParseInt(item, 10, 64); err != nil {
+			return fmt.Errorf("cannot scan %q into an IntArray: %w", item, err)
+		}
+	}
+	return nil
+}
+
+func (a *FloatArray) Scan(src interface{}) error {
+	items, err := scanList(src)
+	if err != nil || items == nil {
+		*a = nil
+		return err
+	}
+	*a = make(FloatArray, len(items))
+	for ix, item := range items {
+		if (*a)[ix], err = strconv.ParseFloat(item, 64); err != nil {
+			return fmt.Errorf("cannot scan %q into a FloatArray: %w", item, err)
+		}
+	}
+	return nil
+}
+
+// scanList returns the items of a Postgres array or JSON array as text
+func scanList(src interface{}) ([]string, error) {
+	var text string
+	switch src := src.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		text = src
+	case []byte:
+		text = string(src)
+	default:
+		return nil, fmt.Errorf("cannot scan %T into a list", src)
+	}
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		if items := jsonItems(text); items != nil {
+			return items, nil
+		}
+		return nil, fmt.Errorf("cannot scan %q into a list", text)
+	}
+	return parsePostgresArray(text)
+}
+
+// parsePostgresArray reads a one dimensional Postgres array literal such as {apple,"pear, ripe",NULL}.
+// NULL items are read as empty
+func parsePostgresArray(text string) ([]string, error) {
+	if !strings.HasPrefix(text, "{") || !strings.HasSuffix(text, "}") {
+		return nil, fmt.Errorf("cannot scan %q into a list", text)
+	}
+	body := text[1 : len(text)-1]
+	items := []string{}
+	if body == "" {
+		return items, nil
+	}
+	var item strings.Builder
+	quoted, inQuotes, escaped := false, false, false
+	for _, r := range body {
+		switch {
+		case escaped:
+			item.WriteRune(r)
+			escaped = false
+		case r == '\\' && inQuotes:
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case r == ',' && !inQuotes:
+			items = append(items, arrayItem(item.String(), quoted))
+			item.Reset()
+			quoted = false
+		default:
+			item.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("cannot scan %q into a list: unterminated quotes", text)
+	}
+	return append(items, arrayItem(item.String(), quoted)), nil
+}
+
+func arrayItem(item string, quoted bool) string {
+	if !quoted && strings.EqualFold(item, "NULL") {
+		return ""
+	}
+	return item
+}
@@ -0,0 +1,123 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertList(t *testing.T) {
+	tests := []struct {
+		input  string
+		params Params
+		want   interface{} // the type to convert to, and the value wanted
+		err    bool
+	}{
+		{input: "apple;pear; plum", want: []string{"apple", "pear", "plum"}},
+		{input: "apple|pear", params: Params{ListSep: "|"}, want: []string{"apple", "pear"}},
+		{input: `["apple","pear, ripe"]`, want: []string{"apple", "pear, ripe"}},
+		{input: "[1,2,3]", want: []int{1, 2, 3}},
+		{input: "1;2;3", want: []int{1, 2, 3}},
+		{input: "1;two;3", want: []int{}, err: true},
+		{input: "", want: []string(nil)},
+		{input: "  ", want: []int(nil)},
+		{input: "[]", want: []int(nil)},
+		{input: "1.5;2,5;50%", want: []float64{1.5, 2.5, 0.5}},
+		{input: "yes;no", want: []bool{true, false}},
+		{input: "[1, 2.5, 3]", want: [3]float64{1, 2.5, 3}},
+		{input: "1;2", want: [3]float64{}, err: true},
+		{input: "", want: [3]float64{}},
+		{input: "a;b", want: []byte("a;b")},
+		{input: "[apple; pear]", want: []string{"[apple", "pear]"}},
+		{input: "apple;pear", want: StringArray{"apple", "pear"}},
+		{input: "1;2", want: IntArray{1, 2}},
+		{input: "[0.5]", want: FloatArray{0.5}},
+	}
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.want)
+		value, err := ConvertString(tt.input, typ, tt.params)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q to %s gave %v, want an error", tt.input, typ, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q to %s: %v", tt.input, typ, err)
+		} else if !reflect.DeepEqual(value.Interface(), tt.want) {
+			t.Errorf("%q to %s gave %#v, want %#v", tt.input, typ, value.Interface(), tt.want)
+		}
+	}
+}
+
+func TestScanList(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want []string
+		err  bool
+	}{
+		{src: nil, want: nil},
+		{src: `{apple,"pear, ripe",NULL,"NULL"}`, want: []string{"apple", "pear, ripe", "", "NULL"}},
+		{src: []byte(`{"say \"hi\"",back\slash}`), want: []string{`say "hi"`, `back\slash`}},
+		{src: "{}", want: []string{}},
+		{src: `["apple","pear"]`, want: []string{"apple", "pear"}},
+		{src: `{"open`, err: true},
+		{src: "apple", err: true},
+		{src: `[oops`, err: true},
+		{src: 12, err: true},
+	}
+	for _, tt := range tests {
+		var got StringArray
+		err := got.Scan(tt.src)
+		if tt.err {
+			if err == nil {
+				t.Errorf("scanning %v gave %q, want an error", tt.src, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("scanning %v: %v", tt.src, err)
+		} else if !reflect.DeepEqual([]string(got), tt.want) {
+			t.Errorf("scanning %v gave %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	var ints IntArray
+	if err := ints.Scan("{1,2,3}"); err != nil || !reflect.DeepEqual(ints, IntArray{1, 2, 3}) {
+		t.Errorf("scanning {1,2,3} gave %v %v", ints, err)
+	}
+	if err := ints.Scan("{1,x}"); err == nil {
+		t.Error("scanning {1,x} into an IntArray did not fail")
+	}
+	var floats FloatArray
+	if err := floats.Scan("[0.5,1e3]"); err != nil || !reflect.DeepEqual(floats, FloatArray{0.5, 1000}) {
+		t.Errorf("scanning [0.5,1e3] gave %v %v", floats, err)
+	}
+}
+
+type testBasket struct {
+	ID      uint
+	Name    string      `xtg:"col:Name"`
+	Fruit   StringArray `xtg:"col:Fruit,split:|"`
+	Counts  IntArray    `xtg:"col:Counts"`
+	Weights FloatArray  `xtg:"col:Weights"`
+}
+
+// lists are read from the file with the separator of the split: sub-tag, and stored and read back by gorm
+func TestImportLists(t *testing.T) {
+	content := "Name;Fruit;Counts;Weights\nMixed;apple|pear;[3,4];\nEmpty;;;[]\n"
+	db := testDB(t)
+	if _, err := Import(db, tempCSV(t, content), ';', &testBasket{}, ImportParams{Migrate: true}); err != nil {
+		t.Fatal(err)
+	}
+	var baskets []testBasket
+	if err := db.Order("id").Find(&baskets).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []testBasket{
+		{ID: 1, Name: "Mixed", Fruit: StringArray{"apple", "pear"}, Counts: IntArray{3, 4}},
+		{ID: 2, Name: "Empty"},
+	}
+	if !reflect.DeepEqual(baskets, want) {
+		t.Errorf("got %+v, want %+v", baskets, want)
+	}
+}
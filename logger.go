@@ -0,0 +1,109 @@
+package csv_to_gorm
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// Logger receives the messages written while reading a file.  Messages are followed by alternating keys and values,
+// in the style of log/slog, whose *slog.Logger satisfies this interface as is.  Nothing is logged unless a Logger
+// is given in the params
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// NopLogger discards every message.  It is the default when the params have no Logger
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, args ...interface{}) {}
+func (NopLogger) Info(msg string, args ...interface{})  {}
+func (NopLogger) Warn(msg string, args ...interface{})  {}
+func (NopLogger) Error(msg string, args ...interface{}) {}
+
+// Progress is passed to the Progress callback of the params as a file is read
+type Progress struct {
+	RowsRead   int   // rows of the file handled so far, including the heading row
+	Records    int   // records produced so far.  Rejected rows make no records
+	BytesRead  int64 // bytes read from the file so far.  Reading is buffered, so this runs a little ahead of RowsRead
+	TotalBytes int64 // size of the file, or -1 if it is not known, such as for a pipe
+	Done       bool  // set on the last call, once the whole file has been read
+}
+
+// progressEvery is how many rows are read between calls to the Progress callback
+const progressEvery = 10000
+
+func (params Params) logger() Logger {
+	if params.Logger == nil {
+		return NopLogger{}
+	}
+	return params.Logger
+}
+
+// countingReader counts the bytes read through it, and copies them to tee if set.
+// The count may be read from another goroutine
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	tee io.Writer
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	if c.tee != nil && n > 0 {
+		c.tee.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *countingReader) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// progressReporter keeps count of the progress through a file and passes it to the Progress callback of the params
+type progressReporter struct {
+	Progress
+	fn      func(Progress)
+	counter *countingReader
+}
+
+// newProgressReporter returns a reporter for the file, and the reader through which the file should be read.
+// Everything read is also written to tee, if not nil
+func newProgressReporter(file *os.File, params Params, tee io.Writer) (*progressReporter, io.Reader) {
+	p := &progressReporter{
+		Progress: Progress{TotalBytes: -1},
+		fn:       params.Progress,
+		counter:  &countingReader{r: file, tee: tee},
+	}
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() {
+		p.TotalBytes = info.Size()
+	}
+	return p, p.counter
+}
+
+// row counts a row which made the given number of records
+func (p *progressReporter) row(records int) {
+	p.RowsRead++
+	p.Records += records
+	if p.RowsRead%progressEvery == 0 {
+		p.report(false)
+	}
+}
+
+// done reports that the whole file has been read
+func (p *progressReporter) done() {
+	p.report(true)
+}
+
+func (p *progressReporter) report(done bool) {
+	if p.fn == nil {
+		return
+	}
+	p.BytesRead = p.counter.count()
+	p.Done = done
+	p.fn(p.Progress)
+}
@@ -0,0 +1,14 @@
+//go:build go1.21
+// +build go1.21
+
+package csv_to_gorm
+
+import "log/slog"
+
+// NewSlogLogger returns a Logger writing to l, or to slog.Default() if l is nil
+func NewSlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l
+}
@@ -0,0 +1,77 @@
+package csv_to_gorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger keeps the messages logged, as level: message key=value
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) log(level string, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	line := level + ": " + msg
+	for ix := 0; ix+1 < len(args); ix += 2 {
+		line += fmt.Sprintf(" %v=%v", args[ix], args[ix+1])
+	}
+	l.messages = append(l.messages, line)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.log("debug", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.log("info", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.log("warn", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.log("error", msg, args) }
+
+func TestLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	content := "Name;Diameter;Found\nCox;6.5;1825\nGala;7;1934\n"
+	_, err := CsvToSlice(tempCSV(t, content), ';', &testFruit{}, Params{Logger: logger})
+	checkErrs(t, err, []string{"Organic"})
+	want := []string{
+		"warn: could not find column header header=Organic model=testFruit",
+		"debug: reached end of input file rows=3 records=2",
+	}
+	if !reflect.DeepEqual(logger.messages, want) {
+		t.Errorf("logged %q, want %q", logger.messages, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Name;Diameter;Found;Organic\n")
+	for i := 0; i < 25000; i++ {
+		fmt.Fprintf(&sb, "Apple %d;6.5;%d;true\n", i, i)
+	}
+	// a row with an error counts as read, but makes no record
+	sb.WriteString("Bad;6.5;x;true\n")
+	size := int64(sb.Len())
+
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			var reports []Progress
+			params := Params{Workers: workers, Progress: func(p Progress) { reports = append(reports, p) }}
+			CsvToSlice(tempCSV(t, sb.String()), ';', &testFruit{}, params)
+
+			if len(reports) != 3 {
+				t.Fatalf("%d reports, want 3: %+v", len(reports), reports)
+			}
+			for ix, rows := range []int{10000, 20000} {
+				p := reports[ix]
+				if p.RowsRead != rows || p.Records != rows-1 || p.Done || p.TotalBytes != size || p.BytesRead <= 0 {
+					t.Errorf("report %d is %+v", ix+1, p)
+				}
+			}
+			last := Progress{RowsRead: 25002, Records: 25000, BytesRead: size, TotalBytes: size, Done: true}
+			if reports[2] != last {
+				t.Errorf("last report is %+v, want %+v", reports[2], last)
+			}
+		})
+	}
+}
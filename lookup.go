@@ -0,0 +1,275 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// MissingParent says what a lookup: field does with a cell matching no row of the parent table
+type MissingParent int
+
+const (
+	FailMissing   MissingParent = iota // the cell is an error, so the row is rejected
+	SkipMissing                        // the record is left out, without an error
+	CreateMissing                      // a parent is created with the cell as its lookup column
+)
+
+var missingParents = map[string]MissingParent{"fail": FailMissing, "skip": SkipMissing, "create": CreateMissing}
+
+// lookupSpec is a parsed lookup: sub-tag, such as lookup:Apple.Name->ID;create
+type lookupSpec struct {
+	model   string // name of the parent model
+	match   string // field of the parent holding the cell's value
+	result  string // field of the parent the lookup field is set to
+	missing MissingParent
+	set     bool // whether missing was given in the tag rather than left to the Lookups
+}
+
+// parseLookup reads the part of a lookup: sub-tag after the colon.  The result field defaults to ID
+func parseLookup(spec string) (lookupSpec, error) {
+	var ls lookupSpec
+	parts := strings.Split(spec, ";")
+	if len(parts) > 2 {
+		return ls, errors.New("lookup " + spec + " should be in the form <Model>.<Field>-><Field>;<fail|skip|create>")
+	}
+	if len(parts) == 2 {
+		missing, ok := missingParents[parts[1]]
+		if !ok {
+			return ls, errors.New("lookup " + spec + ": missing parents must be fail, skip or create, not " + parts[1])
+		}
+		ls.missing, ls.set = missing, true
+	}
+	ls.result = "ID"
+	from := parts[0]
+	if ix := strings.Index(from, "->"); ix >= 0 {
+		from, ls.result = from[:ix], from[ix+2:]
+	}
+	dot := strings.Index(from, ".")
+	if dot < 1 || dot == len(from)-1 || ls.result == "" {
+		return ls, errors.New("lookup " + spec + " should be in the form <Model>.<Field>-><Field>;<fail|skip|create>")
+	}
+	ls.model, ls.match = from[:dot], from[dot+1:]
+	return ls, nil
+}
+
+var (
+	modelsMu sync.RWMutex
+	models   = make(map[string]reflect.Type)
+)
+
+// RegisterModel makes models known by their type name to lookup: fields, so that parents are read and created
+// through gorm, with their timestamps and hooks.  The parents of unregistered models are found in the table
+// gorm would give a model of that name, and created with only their lookup column set
+func RegisterModel(models ...interface{}) {
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		registerModelType(typ)
+	}
+}
+
+func registerModelType(typ reflect.Type) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models[typ.Name()] = typ
+}
+
+func lookupModel(name string) (reflect.Type, bool) {
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+	typ, ok := models[name]
+	return typ, ok
+}
+
+// Lookups resolves the cells of lookup: fields to the keys of their parents, caching what it finds.
+// Import makes one from its db if the params have none.  A Lookups is safe to share between goroutines,
+// but should not outlive a transaction which might create parents and then roll back
+type Lookups struct {
+	db      *gorm.DB
+	Missing MissingParent // for lookup: fields which do not say.  FailMissing if not set
+	Preload bool          // read the whole parent table the first time it is used, rather than a value at a time
+
+	mu     sync.Mutex
+	tables map[lookupSpec]*lookupTable
+}
+
+// NewLookups returns a Lookups reading the parent tables through db
+func NewLookups(db *gorm.DB) *Lookups {
+	return &Lookups{db: db.Session(&gorm.Session{NewDB: true}), tables: make(map[lookupSpec]*lookupTable)}
+}
+
+// lookupTable is the cache of one lookup
+type lookupTable struct {
+	table     string
+	matchCol  string
+	resultCol string
+	modelTyp  reflect.Type // nil if the model is not registered
+	loaded    bool         // the whole table has been read
+	values    map[string]interface{}
+	missing   map[string]bool // values known not to be in the table
+}
+
+func (l *Lookups) table(spec lookupSpec) (*lookupTable, error) {
+	spec.missing, spec.set = 0, false
+	if lt, ok := l.tables[spec]; ok {
+		return lt, nil
+	}
+	lt := &lookupTable{values: make(map[string]interface{}), missing: make(map[string]bool)}
+	if typ, ok := lookupModel(spec.model); ok {
+		stmt := &gorm.Statement{DB: l.db}
+		if err := stmt.Parse(reflect.New(typ).Interface()); err != nil {
+			return nil, err
+		}
+		match, result := stmt.Schema.LookUpField(spec.match), stmt.Schema.LookUpField(spec.result)
+		if match == nil || result == nil {
+			return nil, errors.New("lookup: " + spec.model + " has no field " + spec.match + " or " + spec.result)
+		}
+		lt.table, lt.matchCol, lt.resultCol, lt.modelTyp = stmt.Table, match.DBName, result.DBName, typ
+	} else {
+		naming := l.db.NamingStrategy
+		lt.table = naming.TableName(spec.model)
+		lt.matchCol = naming.ColumnName(lt.table, spec.match)
+		lt.resultCol = naming.ColumnName(lt.table, spec.result)
+	}
+	if l.Preload {
+		var rows []map[string]interface{}
+		err := l.query(lt).Select(lt.matchCol, lt.resultCol).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			lt.values[fmt.Sprint(row[lt.matchCol])] = row[lt.resultCol]
+		}
+		lt.loaded = true
+	}
+	l.tables[spec] = lt
+	return lt, nil
+}
+
+// query starts a query of the parent table.  Through the model, if registered, so that soft deleted parents are not found
+func (l *Lookups) query(lt *lookupTable) *gorm.DB {
+	if lt.modelTyp != nil {
+		return l.db.Model(reflect.New(lt.modelTyp).Interface())
+	}
+	return l.db.Table(lt.table)
+}
+
+// resolve returns the key of the parent whose lookup column holds cell.  found is false if there is none
+// and the record should be skipped
+func (l *Lookups) resolve(spec lookupSpec, cell string) (key interface{}, found bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lt, err := l.table(spec)
+	if err != nil {
+		return nil, false, err
+	}
+	if key, ok := lt.values[cell]; ok {
+		return key, true, nil
+	}
+	if !lt.loaded && !lt.missing[cell] {
+		var rows []map[string]interface{}
+		err := l.query(lt).Select(lt.resultCol).Where(map[string]interface{}{lt.matchCol: cell}).Limit(1).Find(&rows).Error
+		if err != nil {
+			return nil, false, err
+		}
+		if len(rows) > 0 {
+			lt.values[cell] = rows[0][lt.resultCol]
+			return rows[0][lt.resultCol], true, nil
+		}
+		lt.missing[cell] = true
+	}
+
+	missing := l.Missing
+	if spec.set {
+		missing = spec.missing
+	}
+	switch missing {
+	case SkipMissing:
+		return nil, false, nil
+	case CreateMissing:
+		key, err := l.create(lt, spec, cell)
+		if err != nil {
+			return nil, false, err
+		}
+		lt.values[cell] = key
+		return key, true, nil
+	}
+	return nil, false, fmt.Errorf("no %s with %s %q", spec.model, spec.match, cell)
+}
+
+// create adds a parent with the cell in its lookup column, returning its key
+func (l *Lookups) create(lt *lookupTable, spec lookupSpec, cell string) (interface{}, error) {
+	if lt.modelTyp != nil {
+		parent := reflect.New(lt.modelTyp)
+		stmt := &gorm.Statement{DB: l.db}
+		if err := stmt.Parse(parent.Interface()); err != nil {
+			return nil, err
+		}
+		match, err := ConvertString(cell, stmt.Schema.LookUpField(spec.match).FieldType, Params{})
+		if err != nil {
+			return nil, fmt.Errorf("creating %s: %w", spec.model, err)
+		}
+		if err := stmt.Schema.LookUpField(spec.match).Set(parent.Elem(), match.Interface()); err != nil {
+			return nil, err
+		}
+		if err := l.db.Create(parent.Interface()).Error; err != nil {
+			return nil, fmt.Errorf("creating %s: %w", spec.model, err)
+		}
+		key, _ := stmt.Schema.LookUpField(spec.result).ValueOf(parent.Elem())
+		return key, nil
+	}
+
+	if err := l.db.Table(lt.table).Create(map[string]interface{}{lt.matchCol: cell}).Error; err != nil {
+		return nil, fmt.Errorf("creating %s: %w", spec.model, err)
+	}
+	var rows []map[string]interface{}
+	err := l.db.Table(lt.table).Select(lt.resultCol).Where(map[string]interface{}{lt.matchCol: cell}).Limit(1).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("created %s with %s %q, but cannot find it", spec.model, spec.match, cell)
+	}
+	return rows[0][lt.resultCol], nil
+}
+
+// lookupValue converts the key of a parent to the type of the lookup field
+func lookupValue(key interface{}, outType reflect.Type) (reflect.Value, error) {
+	elemType := outType
+	if outType.Kind() == reflect.Ptr {
+		elemType = outType.Elem()
+	}
+	keyValue := reflect.ValueOf(key)
+	if !keyValue.IsValid() {
+		return reflect.Zero(outType), nil
+	}
+	if keyValue.Type() == reflect.TypeOf([]byte(nil)) {
+		keyValue = reflect.ValueOf(string(key.([]byte)))
+	}
+	var value reflect.Value
+	if keyValue.Kind() == reflect.String && elemType.Kind() != reflect.String {
+		converted, err := ConvertString(keyValue.String(), elemType, Params{})
+		if err != nil {
+			return reflect.Zero(outType), err
+		}
+		value = converted
+	} else if keyValue.Type().ConvertibleTo(elemType) {
+		value = keyValue.Convert(elemType)
+	} else {
+		return reflect.Zero(outType), fmt.Errorf("cannot use key %v of type %s for a %s", key, keyValue.Type(), outType)
+	}
+	if outType.Kind() == reflect.Ptr {
+		ptr := reflect.New(elemType)
+		ptr.Elem().Set(value)
+		return ptr, nil
+	}
+	return value, nil
+}
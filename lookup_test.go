@@ -0,0 +1,162 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLookup(t *testing.T) {
+	tests := []struct {
+		spec string
+		want lookupSpec
+		err  bool
+	}{
+		{spec: "Apple.Name", want: lookupSpec{model: "Apple", match: "Name", result: "ID"}},
+		{spec: "Apple.Name->Code", want: lookupSpec{model: "Apple", match: "Name", result: "Code"}},
+		{spec: "Apple.Name->ID;create", want: lookupSpec{model: "Apple", match: "Name", result: "ID", missing: CreateMissing, set: true}},
+		{spec: "Apple.Name;skip", want: lookupSpec{model: "Apple", match: "Name", result: "ID", missing: SkipMissing, set: true}},
+		{spec: "Apple.Name;fail", want: lookupSpec{model: "Apple", match: "Name", result: "ID", missing: FailMissing, set: true}},
+		{spec: "Apple.Name;ignore", err: true},
+		{spec: "Apple.Name;skip;create", err: true},
+		{spec: "Apple", err: true},
+		{spec: ".Name", err: true},
+		{spec: "Apple.", err: true},
+		{spec: "Apple.Name->", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLookup(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s gave %+v, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+		} else if got != tt.want {
+			t.Errorf("%s gave %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+type testOrchard struct {
+	ID   uint
+	Name string
+}
+
+type testPlanting struct {
+	ID        uint
+	OrchardID uint `xtg:"col:Orchard,lookup:testOrchard.Name->ID"`
+	Trees     int  `xtg:"col:Trees"`
+}
+
+func TestImportLookups(t *testing.T) {
+	RegisterModel(&testOrchard{})
+	content := "Orchard;Trees\nHome;10\nHill;20\nHome;30\n"
+	tests := []struct {
+		name     string
+		missing  MissingParent
+		preload  bool // read the whole table of orchards first
+		want     ImportResult
+		orchards []string     // orchards in the table once imported
+		planted  map[int]uint // orchard of the trees planted
+		errs     []string
+	}{
+		{
+			name:     "fail",
+			missing:  FailMissing,
+			want:     ImportResult{RowsRead: 3, Inserted: 2, Rejected: 1},
+			orchards: []string{"Home"},
+			planted:  map[int]uint{10: 1, 30: 1},
+			errs:     []string{"row 3: ", `no testOrchard with Name "Hill"`},
+		},
+		{
+			name:     "skip",
+			missing:  SkipMissing,
+			want:     ImportResult{RowsRead: 3, Inserted: 2, Skipped: 1},
+			orchards: []string{"Home"},
+			planted:  map[int]uint{10: 1, 30: 1},
+		},
+		{
+			name:     "create",
+			missing:  CreateMissing,
+			want:     ImportResult{RowsRead: 3, Inserted: 3},
+			orchards: []string{"Home", "Hill"},
+			planted:  map[int]uint{10: 1, 20: 2, 30: 1},
+		},
+		{
+			name:     "create with the table preloaded",
+			missing:  CreateMissing,
+			preload:  true,
+			want:     ImportResult{RowsRead: 3, Inserted: 3},
+			orchards: []string{"Home", "Hill"},
+			planted:  map[int]uint{10: 1, 20: 2, 30: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testDB(t)
+			if err := db.AutoMigrate(&testOrchard{}, &testPlanting{}); err != nil {
+				t.Fatal(err)
+			}
+			db.Create(&testOrchard{Name: "Home"})
+
+			lookups := NewLookups(db)
+			lookups.Missing, lookups.Preload = tt.missing, tt.preload
+			params := ImportParams{Params: Params{Lookups: lookups}}
+			result, err := Import(db, tempCSV(t, content), ';', &testPlanting{}, params)
+			checkErrs(t, err, tt.errs)
+			if result != tt.want {
+				t.Errorf("got %+v, want %+v", result, tt.want)
+			}
+
+			var orchards []string
+			db.Model(&testOrchard{}).Order("id").Pluck("name", &orchards)
+			if !reflect.DeepEqual(orchards, tt.orchards) {
+				t.Errorf("orchards %v, want %v", orchards, tt.orchards)
+			}
+			var plantings []testPlanting
+			db.Find(&plantings)
+			planted := make(map[int]uint)
+			for _, p := range plantings {
+				planted[p.Trees] = p.OrchardID
+			}
+			if !reflect.DeepEqual(planted, tt.planted) {
+				t.Errorf("planted %v, want %v", planted, tt.planted)
+			}
+		})
+	}
+}
+
+type testGroveTree struct {
+	ID      uint
+	GroveID uint   `xtg:"col:Grove,lookup:TestGrove.Name;create"`
+	Variety string `xtg:"col:Variety"`
+}
+
+// the parents of a model which is not registered are found and created in the table gorm would give it
+func TestLookupUnregistered(t *testing.T) {
+	db := testDB(t)
+	if err := db.Exec("CREATE TABLE test_groves (id integer PRIMARY KEY AUTOINCREMENT, name text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Exec("INSERT INTO test_groves (name) VALUES ('East')")
+
+	content := "Grove;Variety\nWest;Cox\nEast;Gala\nWest;Fuji\n"
+	result, err := Import(db, tempCSV(t, content), ';', &testGroveTree{}, ImportParams{Migrate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 3, Inserted: 3}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	var groves []uint
+	db.Model(&testGroveTree{}).Order("id").Pluck("grove_id", &groves)
+	if !reflect.DeepEqual(groves, []uint{2, 1, 2}) {
+		t.Errorf("trees are in groves %v", groves)
+	}
+
+	// CsvToSlice needs the Lookups to be given
+	_, err = CsvToSlice(tempCSV(t, content), ';', &testGroveTree{}, Params{})
+	checkErrs(t, err, []string{"need the Lookups of the params to be set"})
+}
@@ -0,0 +1,401 @@
+package csv_to_gorm
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping describes how the columns of a CSV file become the fields of a model, as an alternative
+// to xtg struct tags, so that new file layouts can be handled without recompiling.
+// Mappings are usually loaded from a YAML or JSON file with LoadMapping:
+//
+//	fields:
+//	  - name: Name
+//	    col: Name
+//	    aliases: [Apple, Variety]
+//	    required: true
+//	  - name: Product
+//	    mapConst: product
+//	  - name: Year
+//	    intcols: colname
+//	  - name: Yield
+//	    intcols: value
+//	    min: 0
+//	constMap:
+//	  product: apple
+//	ignore: [Notes]
+//
+// Precedence, from highest to lowest:
+//   - Params.ColMap
+//   - the mapping of a field
+//   - the xtg tag of the field
+//
+// Where a field mapping gives the source of the field's value (col, colNo, mapConst, intcols or melt),
+// that replaces the source given by the tag.  Any other attribute set in the field mapping replaces the
+// same attribute of the tag, leaving the rest of the tag in force.  Set replaceTag to ignore the tag entirely.
+// Constants in Params.ConstMap take precedence over those in the mapping's constMap
+type Mapping struct {
+	Fields   []FieldMapping    `json:"fields" yaml:"fields"`
+	ConstMap map[string]string `json:"constMap,omitempty" yaml:"constMap,omitempty"`
+	Ignore   []string          `json:"ignore,omitempty" yaml:"ignore,omitempty"` // columns ignored for melt
+	// ValueMaps are tables of replacement values for the map-values transform, by name
+	ValueMaps map[string]map[string]string `json:"valueMaps,omitempty" yaml:"valueMaps,omitempty"`
+	DropEmpty bool                         `json:"dropEmpty,omitempty" yaml:"dropEmpty,omitempty"` // leave out records whose value cells are all empty
+}
+
+// FieldMapping holds the same instructions as an xtg tag for one field
+type FieldMapping struct {
+	Name       string            `json:"name" yaml:"name"` // name of the struct field
+	Col        string            `json:"col,omitempty" yaml:"col,omitempty"`
+	Aliases    []string          `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	ColNo      int               `json:"colNo,omitempty" yaml:"colNo,omitempty"` // column number starting at 1
+	MapConst   string            `json:"mapConst,omitempty" yaml:"mapConst,omitempty"`
+	IntCols    string            `json:"intcols,omitempty" yaml:"intcols,omitempty"`     // colname, value or a group of the headcols
+	HeadCols   string            `json:"headcols,omitempty" yaml:"headcols,omitempty"`   // integer, quarter, date:<layout> or regex:<regexp>
+	Melt       string            `json:"melt,omitempty" yaml:"melt,omitempty"`           // colname or value
+	MeltGroup  string            `json:"meltGroup,omitempty" yaml:"meltGroup,omitempty"` // name of the melt group, if not the plain melt
+	MeltCols   string            `json:"meltcols,omitempty" yaml:"meltcols,omitempty"`   // prefix:<prefix>, list:<heading>;<heading> or regex:<regexp>
+	MeltSplit  string            `json:"meltsplit,omitempty" yaml:"meltsplit,omitempty"` // delim:<delimiter> or regex:<regexp>
+	Ignore     []string          `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+	Conv       string            `json:"conv,omitempty" yaml:"conv,omitempty"`
+	Required   bool              `json:"required,omitempty" yaml:"required,omitempty"`
+	Min        *float64          `json:"min,omitempty" yaml:"min,omitempty"`
+	Max        *float64          `json:"max,omitempty" yaml:"max,omitempty"`
+	OneOf      []string          `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	Pattern    string            `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Transform  []string          `json:"transform,omitempty" yaml:"transform,omitempty"` // e.g. [trim, "strip-suffix= cm"]
+	Values     map[string]string `json:"values,omitempty" yaml:"values,omitempty"`       // the only cells allowed, and what each stands for
+	Split      string            `json:"split,omitempty" yaml:"split,omitempty"`         // separates the items of slice and array fields
+	Bools      []string          `json:"bools,omitempty" yaml:"bools,omitempty"`         // en, de, fr, es, 01 or x
+	Key        bool              `json:"key,omitempty" yaml:"key,omitempty"`
+	Meta       string            `json:"meta,omitempty" yaml:"meta,omitempty"`     // rownum, filename, sheet, importid or sourcecol
+	Lookup     string            `json:"lookup,omitempty" yaml:"lookup,omitempty"` // <Model>.<Field>-><Field>;<fail|skip|create>
+	Assoc      bool              `json:"assoc,omitempty" yaml:"assoc,omitempty"`
+	Type       string            `json:"type,omitempty" yaml:"type,omitempty"` // go type of the field, for models built at run time
+	ReplaceTag bool              `json:"replaceTag,omitempty" yaml:"replaceTag,omitempty"`
+}
+
+// LoadMapping reads a mapping file.  Files ending in .yaml or .yml are read as YAML, anything else as JSON
+func LoadMapping(fileName string) (*Mapping, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	format := "json"
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		format = "yaml"
+	}
+	mapping, err := ParseMapping(content, format)
+	if err != nil {
+		return nil, errors.New("mapping file " + fileName + ": " + err.Error())
+	}
+	return mapping, nil
+}
+
+// ParseMapping reads a mapping from its content.  format is either json or yaml
+func ParseMapping(content []byte, format string) (*Mapping, error) {
+	var mapping Mapping
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(content, &mapping)
+	case "yaml":
+		err = yaml.Unmarshal(content, &mapping)
+	default:
+		return nil, errors.New("unknown mapping format " + format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &mapping, mapping.check()
+}
+
+// check looks for mistakes which would otherwise only show when reading a file
+func (m *Mapping) check() error {
+	names := make(map[string]bool)
+	for _, fm := range m.Fields {
+		if fm.Name == "" {
+			return errors.New("field without a name")
+		}
+		if names[fm.Name] {
+			return errors.New("field " + fm.Name + " is mapped more than once")
+		}
+		names[fm.Name] = true
+		switch strings.ToLower(fm.Melt) {
+		case "", "colname", "value", "none":
+		default:
+			return errors.New("field " + fm.Name + ": melt must be colname, value or none, not " + fm.Melt)
+		}
+		if fm.MeltCols != "" {
+			if _, err := parseMeltCols(fm.MeltGroup, fm.MeltCols); err != nil {
+				return errors.New("field " + fm.Name + ": " + err.Error())
+			}
+		}
+		if fm.MeltSplit != "" {
+			if _, err := parseMeltSplit(fm.MeltSplit); err != nil {
+				return errors.New("field " + fm.Name + ": " + err.Error())
+			}
+		}
+		if fm.HeadCols != "" {
+			if _, err := parseHeadCols(fm.HeadCols); err != nil {
+				return errors.New("field " + fm.Name + ": " + err.Error())
+			}
+		}
+		if fm.Meta != "" && !isMeta(fm.Meta) {
+			return errors.New("field " + fm.Name + ": meta must be rownum, filename, sheet, importid or sourcecol, not " + fm.Meta)
+		}
+		if fm.Lookup != "" {
+			if _, err := parseLookup(fm.Lookup); err != nil {
+				return errors.New("field " + fm.Name + ": " + err.Error())
+			}
+		}
+		if fm.Pattern != "" {
+			if _, err := regexp.Compile(fm.Pattern); err != nil {
+				return errors.New("field " + fm.Name + ": pattern is not a valid regular expression: " + err.Error())
+			}
+		}
+		if fm.Type != "" {
+			if _, ok := TypeByName(fm.Type); !ok {
+				return errors.New("field " + fm.Name + " has unknown type " + fm.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// checkFields makes sure every field the mapping names is a field of the model, as the mapping of a
+// misspelt field would otherwise be ignored.  The mapping may be nil
+func (m *Mapping) checkFields(typ reflect.Type) error {
+	if m == nil {
+		return nil
+	}
+	names := make(map[string]bool, typ.NumField())
+	for fldIx := 0; fldIx < typ.NumField(); fldIx++ {
+		names[typ.Field(fldIx).Name] = true
+	}
+	var unknown []string
+	for _, fm := range m.Fields {
+		if !names[fm.Name] {
+			unknown = append(unknown, fm.Name)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.New("the mapping names fields which " + typ.Name() + " does not have: " + strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// valueMap returns the named table of the mapping's ValueMaps.  The mapping may be nil
+func (m *Mapping) valueMap(name string) (map[string]string, bool) {
+	if m == nil {
+		return nil, false
+	}
+	values, ok := m.ValueMaps[name]
+	return values, ok
+}
+
+// Field returns the mapping of the named struct field
+func (m *Mapping) Field(name string) (FieldMapping, bool) {
+	for _, fm := range m.Fields {
+		if fm.Name == name {
+			return fm, true
+		}
+	}
+	return FieldMapping{}, false
+}
+
+// StructFields builds the fields of a struct able to hold the mapped fields, for use with reflect.StructOf.
+// Every field mapping must have a type
+func (m *Mapping) StructFields() ([]reflect.StructField, error) {
+	structFlds := make([]reflect.StructField, 0, len(m.Fields))
+	for _, fm := range m.Fields {
+		typ, ok := TypeByName(fm.Type)
+		if !ok {
+			return nil, errors.New("field " + fm.Name + " has no type")
+		}
+		structFlds = append(structFlds, reflect.StructField{Name: fm.Name, Type: typ})
+	}
+	return structFlds, nil
+}
+
+// ResolveTag parses the xtg tag of the field and merges it with the field's entry in the mapping, if any
+func ResolveTag(field reflect.StructField, mapping *Mapping) (Tag, error) {
+	tag, err := ParseTag(field)
+	if err != nil || mapping == nil {
+		return tag, err
+	}
+	fm, ok := mapping.Field(field.Name)
+	if !ok {
+		return tag, nil
+	}
+	if fm.ReplaceTag {
+		tag = Tag{}
+	}
+	tag.HasTag = true
+
+	// a source given by the mapping replaces the source from the tag
+	if fm.Col != "" || fm.ColNo > 0 || fm.MapConst != "" || fm.IntCols != "" || fm.Melt != "" || fm.Meta != "" {
+		tag.HasColanme, tag.Colname, tag.Aliases, tag.ColNo = false, "", nil, 0
+		tag.IsMapConst, tag.ConstMapKey = false, ""
+		tag.IsIntColsHead, tag.IsIntColsValue, tag.IntColsGroup = false, false, ""
+		tag.IsMeltHead, tag.IsMeltValue, tag.MeltGroup = false, false, ""
+		tag.Meta = ""
+	}
+	if fm.Col != "" {
+		tag.HasColanme = true
+		tag.Colname = fm.Col
+	}
+	if fm.Aliases != nil {
+		tag.Aliases = fm.Aliases
+	}
+	if fm.ColNo > 0 {
+		tag.ColNo = fm.ColNo
+	}
+	if fm.MapConst != "" {
+		tag.IsMapConst = true
+		tag.ConstMapKey = fm.MapConst
+	}
+	switch strings.ToLower(fm.IntCols) {
+	case "colname":
+		tag.IsIntColsHead = true
+	case "value":
+		tag.IsIntColsValue = true
+	case "", "none":
+	default:
+		tag.IntColsGroup = fm.IntCols
+	}
+	if fm.HeadCols != "" {
+		tag.HeadCols = fm.HeadCols
+	}
+	switch strings.ToLower(fm.Melt) {
+	case "colname":
+		tag.IsMeltHead = true
+		tag.MeltGroup = fm.MeltGroup
+	case "value":
+		tag.IsMeltValue = true
+		tag.MeltGroup = fm.MeltGroup
+	}
+	if fm.MeltCols != "" {
+		tag.MeltCols = fm.MeltCols
+	}
+	if fm.MeltSplit != "" {
+		tag.MeltSplit = fm.MeltSplit
+	}
+	if fm.Meta != "" {
+		tag.Meta = fm.Meta
+	}
+	if fm.Lookup != "" {
+		tag.Lookup = fm.Lookup
+	}
+	if fm.Assoc {
+		tag.IsAssoc = true
+	}
+
+	// the remaining attributes replace their counterpart in the tag one by one
+	if fm.Ignore != nil {
+		tag.Ignore = fm.Ignore
+	}
+	if fm.Conv != "" {
+		tag.Converter = fm.Conv
+	}
+	if fm.Required {
+		tag.Required = true
+	}
+	if fm.Key {
+		tag.IsKey = true
+	}
+	if fm.Min != nil {
+		tag.Min = fm.Min
+	}
+	if fm.Max != nil {
+		tag.Max = fm.Max
+	}
+	if fm.OneOf != nil {
+		tag.OneOf = fm.OneOf
+	}
+	if fm.Transform != nil {
+		tag.Transform = fm.Transform
+	}
+	if fm.Values != nil {
+		tag.Values = fm.Values
+	}
+	if fm.Split != "" {
+		tag.Split = fm.Split
+	}
+	if fm.Bools != nil {
+		bools, err := parseBools(strings.Join(fm.Bools, ";"))
+		if err != nil {
+			return tag, errors.New("field " + fm.Name + ": " + err.Error())
+		}
+		tag.Bools = bools
+	}
+	if fm.Pattern != "" {
+		tag.Pattern = fm.Pattern
+	}
+	return tag, nil
+}
+
+// withMapping returns a copy of the params with the constants of the mapping added to the ConstMap
+func (params Params) withMapping() Params {
+	if params.Mapping == nil || len(params.Mapping.ConstMap) == 0 {
+		return params
+	}
+	constMap := make(map[string]string, len(params.ConstMap)+len(params.Mapping.ConstMap))
+	for key, value := range params.Mapping.ConstMap {
+		constMap[key] = value
+	}
+	for key, value := range params.ConstMap {
+		constMap[key] = value
+	}
+	params.ConstMap = constMap
+	return params
+}
+
+// colNo returns the column number (starting at 1) of the first of the tag's column name and its aliases
+// found in the headings, or 0 if none are found
+func (tag Tag) colNo(headingCols map[string]int) int {
+	if colNo := headingCols[tag.Colname]; colNo > 0 {
+		return colNo
+	}
+	// spreadsheet programs like to start files with a byte order mark
+	if colNo := headingCols["\ufeff"+tag.Colname]; colNo > 0 {
+		return colNo
+	}
+	for _, alias := range tag.Aliases {
+		if colNo := headingCols[alias]; colNo > 0 {
+			return colNo
+		}
+	}
+	return 0
+}
+
+var typesByName = map[string]reflect.Type{
+	"string":  reflect.TypeOf(""),
+	"bool":    reflect.TypeOf(false),
+	"int":     reflect.TypeOf(int(0)),
+	"int8":    reflect.TypeOf(int8(0)),
+	"int16":   reflect.TypeOf(int16(0)),
+	"int32":   reflect.TypeOf(int32(0)),
+	"int64":   reflect.TypeOf(int64(0)),
+	"uint":    reflect.TypeOf(uint(0)),
+	"uint8":   reflect.TypeOf(uint8(0)),
+	"uint16":  reflect.TypeOf(uint16(0)),
+	"uint32":  reflect.TypeOf(uint32(0)),
+	"uint64":  reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+}
+
+// TypeByName returns the type of the go basic type with the given name, e.g. float64
+func TypeByName(name string) (reflect.Type, bool) {
+	typ, ok := typesByName[name]
+	return typ, ok
+}
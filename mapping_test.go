@@ -0,0 +1,109 @@
+package csv_to_gorm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		err     string // part of the error expected, empty if none
+	}{
+		{"yaml", "fields:\n  - name: Name\n    col: Variety\n    aliases: [Cultivar]\nconstMap:\n  product: apple\n", "yaml", ""},
+		{"json", `{"fields": [{"name": "Name", "col": "Variety", "aliases": ["Cultivar"]}], "constMap": {"product": "apple"}}`, "json", ""},
+		{"unknown format", "fields: []", "toml", "unknown mapping format toml"},
+		{"bad yaml", "fields: [", "yaml", "yaml"},
+		{"field without a name", `{"fields": [{"col": "Variety"}]}`, "json", "field without a name"},
+		{"field mapped twice", `{"fields": [{"name": "Name"}, {"name": "Name"}]}`, "json", "field Name is mapped more than once"},
+		{"bad melt", `{"fields": [{"name": "Name", "melt": "values"}]}`, "json", "melt must be colname, value or none"},
+		{"bad headcols", `{"fields": [{"name": "Year", "headcols": "fiscal"}]}`, "json", "field Year: headcols must be"},
+		{"bad meta", `{"fields": [{"name": "Row", "meta": "line"}]}`, "json", "field Row: meta must be"},
+		{"bad pattern", `{"fields": [{"name": "Name", "pattern": "("}]}`, "json", "pattern is not a valid regular expression"},
+		{"unknown type", `{"fields": [{"name": "Name", "type": "complex128"}]}`, "json", "field Name has unknown type complex128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := ParseMapping([]byte(tt.content), tt.format)
+			if tt.err != "" {
+				checkErrs(t, err, []string{tt.err})
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			fm, ok := mapping.Field("Name")
+			if !ok || fm.Col != "Variety" || !reflect.DeepEqual(fm.Aliases, []string{"Cultivar"}) || mapping.ConstMap["product"] != "apple" {
+				t.Errorf("read %+v", mapping)
+			}
+		})
+	}
+}
+
+type testVariety struct {
+	Name     string  `xtg:"col:Name"`
+	Diameter float64 `xtg:"col:Diameter,min:0"`
+	Product  string  `xtg:"mapConst:product"`
+}
+
+// the mapping replaces the source of a field given by its tag, and other attributes one at a time
+func TestMappingOverridesTag(t *testing.T) {
+	mappingFile := filepath.Join(t.TempDir(), "varieties.yml")
+	content := "fields:\n  - name: Name\n    col: Variety\n  - name: Diameter\n    max: 7\nconstMap:\n  product: apple\n"
+	if err := os.WriteFile(mappingFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := LoadMapping(mappingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := tempCSV(t, "Name;Variety;Diameter;Size\nApple;Cox;6.5;1\nApple;Gala;-1;2\nApple;Bramley;9;3\n")
+	got, err := CsvToSlice(file, ';', &testVariety{}, Params{Mapping: mapping})
+	want := []testVariety{{"Cox", 6.5, "apple"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// the min of the tag is kept together with the max of the mapping
+	checkErrs(t, err, []string{"row 3: field Diameter", "row 4: field Diameter"})
+
+	// ColMap and the ConstMap of the params override the mapping
+	got, _ = CsvToSlice(file, ';', &testVariety{}, Params{Mapping: mapping, ColMap: map[string]int{"Name": 1, "Diameter": 4},
+		ConstMap: map[string]string{"product": "pear"}})
+	want = []testVariety{{"Apple", 1, "pear"}, {"Apple", 2, "pear"}, {"Apple", 3, "pear"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with ColMap got %+v, want %+v", got, want)
+	}
+
+	// replaceTag drops the min of the tag
+	field, _ := reflect.TypeOf(testVariety{}).FieldByName("Diameter")
+	replaced, err := ParseMapping([]byte(`{"fields": [{"name": "Diameter", "col": "Size", "replaceTag": true}]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := ResolveTag(field, replaced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Colname != "Size" || tag.Min != nil {
+		t.Errorf("replaceTag gave %+v", tag)
+	}
+}
+
+// a mapping naming a field the model does not have is an error, rather than a column quietly left unread
+func TestMappingUnknownFields(t *testing.T) {
+	mapping, err := ParseMapping([]byte(`{"fields": [{"name": "Nmae", "col": "Variety"}, {"name": "Diameter"}, {"name": "Colour", "col": "Skin"}]}`), "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "Variety;Diameter\nCox;6.5\n"
+	_, err = CsvToSlice(tempCSV(t, content), ';', &testVariety{}, Params{Mapping: mapping})
+	checkErrs(t, err, []string{"the mapping names fields which testVariety does not have: Nmae, Colour"})
+	db := testDB(t)
+	_, err = Import(db, tempCSV(t, content), ';', &testVariety{}, ImportParams{Migrate: true, Params: Params{Mapping: mapping}})
+	checkErrs(t, err, []string{"Nmae, Colour"})
+}
@@ -0,0 +1,198 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// meltGroup is a named melt group, such as the cost columns of melt:cost:colname and melt:cost:value fields.
+// Its columns are picked out by a meltcols: sub-tag, and the records of a row are lined up across the groups
+// by member, the part of the heading which is the same in every group, e.g. the pest in "cost aphid" and "loss aphid"
+type meltGroup struct {
+	name string
+	spec string // part of the meltcols: sub-tag after the colon
+	// member returns the member a heading is for, or ok false if the column is not in the group
+	member func(heading string) (member string, ok bool)
+}
+
+// parseMeltCols reads the part of a meltcols: sub-tag after the colon, one of
+// prefix:<prefix>, list:<heading>=<member>;<heading>=<member> or regex:<regexp>
+func parseMeltCols(name string, spec string) (*meltGroup, error) {
+	mg := &meltGroup{name: name, spec: spec}
+	kind, arg := spec, ""
+	if ix := strings.Index(spec, ":"); ix >= 0 {
+		kind, arg = spec[:ix], spec[ix+1:]
+	}
+	if arg == "" {
+		return nil, errors.New("meltcols " + spec + " should be in the form prefix:<prefix>, list:<heading>;<heading> or regex:<regexp>")
+	}
+	switch kind {
+	case "prefix":
+		// the member is the rest of the heading
+		mg.member = func(heading string) (string, bool) {
+			if !strings.HasPrefix(heading, arg) {
+				return "", false
+			}
+			member := strings.TrimSpace(heading[len(arg):])
+			return member, member != ""
+		}
+	case "list":
+		// entries may name the member, as <heading>=<member>
+		members := make(map[string]string)
+		for _, entry := range strings.Split(arg, ";") {
+			heading, member := entry, entry
+			if ix := strings.Index(entry, "="); ix >= 0 {
+				heading, member = entry[:ix], entry[ix+1:]
+			}
+			members[heading] = member
+		}
+		mg.member = func(heading string) (string, bool) {
+			member, ok := members[heading]
+			return member, ok
+		}
+	case "regex":
+		// the member is the first capture group, or the whole heading if there is none
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, errors.New("meltcols regex is not a valid regular expression: " + err.Error())
+		}
+		mg.member = func(heading string) (string, bool) {
+			match := re.FindStringSubmatch(heading)
+			if match == nil {
+				return "", false
+			}
+			if len(match) > 1 {
+				return match[1], match[1] != ""
+			}
+			return heading, true
+		}
+	default:
+		return nil, errors.New("meltcols must be prefix:<prefix>, list:<heading>;<heading> or regex:<regexp>, not " + spec)
+	}
+	return mg, nil
+}
+
+// getMeltGroupCols lines up the columns of the named melt groups by member, then those split by the meltsplit:
+// of the model, followed by the columns melted by plain melt fields if the model has any.  Each entry holds the column of every group having the member,
+// with colIx the first of them.  Columns are taken by the first group to claim them, and none are used or ignored
+func (plan *modelPlan) getMeltGroupCols(colNames []string, notMelted map[int]bool) []headingCol {
+	var meltCols []headingCol
+	members := make(map[string]int)
+	taken := make(map[int]bool)
+	add := func(group string, member string, colIx int) {
+		ix, ok := members[member]
+		if !ok {
+			ix = len(meltCols)
+			members[member] = ix
+			meltCols = append(meltCols, headingCol{heading: member, colIx: colIx, groupCols: make(map[string]int)})
+		}
+		if _, ok := meltCols[ix].groupCols[group]; !ok {
+			meltCols[ix].groupCols[group] = colIx
+		}
+		taken[colIx] = true
+	}
+
+	meltable := func(colIx int, colName string) bool {
+		if colName == "" || notMelted[colIx] || taken[colIx] {
+			return false
+		}
+		_, isIgnored := find(plan.ignore, colName)
+		return !isIgnored
+	}
+	for _, mg := range plan.meltGroups {
+		if mg.member == nil {
+			continue
+		}
+		for colIx, colName := range colNames {
+			if !meltable(colIx, colName) {
+				continue
+			}
+			if member, ok := mg.member(colName); ok {
+				add(mg.name, member, colIx)
+			}
+		}
+	}
+	if plan.meltSplit != nil {
+		for colIx, colName := range colNames {
+			if !meltable(colIx, colName) {
+				continue
+			}
+			member, measure, ok := plan.meltSplit.split(colName)
+			if !ok {
+				continue
+			}
+			for _, mg := range plan.meltGroups {
+				if strings.EqualFold(mg.name, measure) {
+					add(mg.name, member, colIx)
+					break
+				}
+			}
+		}
+	}
+	if plan.hasPlainMelt {
+		for colIx := range notMelted {
+			taken[colIx] = true
+		}
+		for _, col := range getMeltCols(colNames, taken, plan.ignore) {
+			add("", col.heading, col.colIx)
+		}
+	}
+	return meltCols
+}
+
+// meltSplit splits the headings of melt columns into a member and a measure, such as "Fire Blight" and "loss" in
+// "Fire Blight (loss)".  The measure names the melt group the column belongs to, so that the measures of a member
+// fan out into the value fields of one record
+type meltSplit struct {
+	spec  string // part of the meltsplit: sub-tag after the colon
+	split func(heading string) (member string, measure string, ok bool)
+}
+
+// parseMeltSplit reads the part of a meltsplit: sub-tag after the colon, one of
+// delim:<delimiter>, splitting at the last delimiter with the measure after it, or regex:<regexp>, whose groups
+// named member and measure, or else its first two groups, are the member and the measure
+func parseMeltSplit(spec string) (*meltSplit, error) {
+	ms := &meltSplit{spec: spec}
+	kind, arg := spec, ""
+	if ix := strings.Index(spec, ":"); ix >= 0 {
+		kind, arg = spec[:ix], spec[ix+1:]
+	}
+	if arg == "" {
+		return nil, errors.New("meltsplit " + spec + " should be in the form delim:<delimiter> or regex:<regexp>")
+	}
+	switch kind {
+	case "delim":
+		ms.split = func(heading string) (string, string, bool) {
+			ix := strings.LastIndex(heading, arg)
+			if ix < 0 {
+				return "", "", false
+			}
+			member, measure := strings.TrimSpace(heading[:ix]), strings.TrimSpace(heading[ix+len(arg):])
+			return member, measure, member != "" && measure != ""
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, errors.New("meltsplit regex is not a valid regular expression: " + err.Error())
+		}
+		memberIx, measureIx := re.SubexpIndex("member"), re.SubexpIndex("measure")
+		if memberIx < 0 || measureIx < 0 {
+			memberIx, measureIx = 1, 2
+		}
+		if re.NumSubexp() < 2 {
+			return nil, errors.New("meltsplit regex needs groups for the member and the measure")
+		}
+		ms.split = func(heading string) (string, string, bool) {
+			match := re.FindStringSubmatch(heading)
+			if match == nil {
+				return "", "", false
+			}
+			member, measure := strings.TrimSpace(match[memberIx]), strings.TrimSpace(match[measureIx])
+			return member, measure, member != "" && measure != ""
+		}
+	default:
+		return nil, errors.New("meltsplit must be delim:<delimiter> or regex:<regexp>, not " + spec)
+	}
+	return ms, nil
+}
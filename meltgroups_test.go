@@ -0,0 +1,146 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testPestPrefix struct {
+	Variety string  `xtg:"col:Name"`
+	Pest    string  `xtg:"melt:cost:colname"`
+	Cost    float64 `xtg:"melt:cost:value,meltcols:prefix:Cost "`
+	Loss    float64 `xtg:"melt:loss:value,meltcols:prefix:Loss "`
+}
+
+type testPestList struct {
+	Variety string  `xtg:"col:Name"`
+	Pest    string  `xtg:"melt:cost:colname"`
+	Cost    float64 `xtg:"melt:cost:value,meltcols:list:Cost aphid=aphid;Cost mite=mite"`
+	Loss    float64 `xtg:"melt:loss:value,meltcols:regex:^Loss (\\w+)$"`
+}
+
+type testPestLeftover struct {
+	Variety string  `xtg:"col:Name"`
+	Pest    string  `xtg:"melt:cost:colname"`
+	Cost    float64 `xtg:"melt:cost:value,meltcols:prefix:Cost "`
+	Value   float64 `xtg:"melt:value"`
+}
+
+// melt groups are lined up by member, so that each member makes one record holding the values of every group
+func TestMeltGroups(t *testing.T) {
+	content := "Name;Cost aphid;Cost mite;Loss aphid;Loss mite;Area\nCox;1;2;10;20;5\n"
+	tests := []struct {
+		name  string
+		model interface{}
+		want  interface{}
+	}{
+		{
+			name:  "prefix",
+			model: &testPestPrefix{},
+			want:  []testPestPrefix{{"Cox", "aphid", 1, 10}, {"Cox", "mite", 2, 20}},
+		},
+		{
+			name:  "list and regex",
+			model: &testPestList{},
+			want:  []testPestList{{"Cox", "aphid", 1, 10}, {"Cox", "mite", 2, 20}},
+		},
+		{
+			name:  "plain melt of the columns left over",
+			model: &testPestLeftover{},
+			want: []testPestLeftover{
+				{"Cox", "aphid", 1, 0},
+				{"Cox", "mite", 2, 0},
+				{"Cox", "Loss aphid", 0, 10},
+				{"Cox", "Loss mite", 0, 20},
+				{"Cox", "Area", 0, 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CsvToSlice(tempCSV(t, content), ';', tt.model, Params{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v,\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// a group without a column for a member leaves its value field unset
+func TestMeltGroupsMissingMember(t *testing.T) {
+	content := "Name;Cost aphid;Cost mite;Loss mite\nCox;1;2;20\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testPestPrefix{}, Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testPestPrefix{{"Cox", "aphid", 1, 0}, {"Cox", "mite", 2, 20}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseMeltCols(t *testing.T) {
+	for _, spec := range []string{"prefix:", "list:", "regex:(", "suffix: cost"} {
+		if _, err := parseMeltCols("cost", spec); err == nil {
+			t.Errorf("meltcols:%s did not fail", spec)
+		}
+	}
+}
+
+func TestParseMeltSplit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		heading string
+		member  string
+		measure string
+		ok      bool
+	}{
+		{spec: "delim: - ", heading: "Fire Blight - loss", member: "Fire Blight", measure: "loss", ok: true},
+		{spec: "delim:/", heading: "Scab/Tree/cost", member: "Scab/Tree", measure: "cost", ok: true},
+		{spec: "delim:/", heading: "Scab", ok: false},
+		{spec: "delim:/", heading: "Scab/", ok: false},
+		{spec: `regex:^(.+) \((\w+)\)$`, heading: "Fire Blight (loss)", member: "Fire Blight", measure: "loss", ok: true},
+		{spec: `regex:^(?P<measure>\w+) of (?P<member>.+)$`, heading: "cost of Scab", member: "Scab", measure: "cost", ok: true},
+		{spec: `regex:^(.+) \((\w+)\)$`, heading: "Fire Blight", ok: false},
+	}
+	for _, tt := range tests {
+		ms, err := parseMeltSplit(tt.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		member, measure, ok := ms.split(tt.heading)
+		if ok != tt.ok || ok && (member != tt.member || measure != tt.measure) {
+			t.Errorf("%s split %q into %q, %q, %v, want %q, %q, %v", tt.spec, tt.heading, member, measure, ok, tt.member, tt.measure, tt.ok)
+		}
+	}
+
+	for _, spec := range []string{"delim:", "regex:^(.+)$", "regex:(", "suffix:x"} {
+		if _, err := parseMeltSplit(spec); err == nil {
+			t.Errorf("meltsplit:%s did not fail", spec)
+		}
+	}
+}
+
+type testPestSplit struct {
+	Variety string  `xtg:"col:Name"`
+	Pest    string  `xtg:"melt:colname,meltsplit:regex:^(.+) \\((\\w+)\\)$"`
+	Loss    float64 `xtg:"melt:loss:value"`
+	Cost    float64 `xtg:"melt:cost:value"`
+}
+
+// interleaved columns are split into the member and the group named by the measure, whatever its case
+func TestMeltSplit(t *testing.T) {
+	content := "Name;Fire Blight (loss);Fire Blight (Cost);Scab (cost);Scab (loss);Mildew (spread)\nCox;1;2;3;4;5\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testPestSplit{}, Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testPestSplit{{"Cox", "Fire Blight", 1, 2}, {"Cox", "Scab", 4, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+}
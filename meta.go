@@ -0,0 +1,48 @@
+package csv_to_gorm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+)
+
+// isMeta says whether role is one of the meta: roles
+func isMeta(role string) bool {
+	switch role {
+	case "rownum", "filename", "sheet", "importid", "sourcecol":
+		return true
+	}
+	return false
+}
+
+// newImportID makes up an ID for an import which was not given one
+func newImportID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic("csv_to_gorm: cannot make an import ID: " + err.Error())
+	}
+	return hex.EncodeToString(id)
+}
+
+// meta returns the text to fill a meta: field with.  ok is false if there is nothing to fill it with,
+// such as a sourcecol field in a record not made from an intcols or melt column
+func (fp *filePlan) meta(role string, rowNo int, intCol headingCol, meltCol headingCol, params Params) (cell string, ok bool) {
+	switch role {
+	case "rownum":
+		return strconv.Itoa(rowNo), true
+	case "filename":
+		return fp.fileName, true
+	case "sheet":
+		return params.Sheet, true
+	case "importid":
+		return params.ImportID, true
+	case "sourcecol":
+		if fp.hasMelt && meltCol.colIx >= 0 {
+			return strconv.Itoa(meltCol.colIx + 1), true
+		}
+		if fp.hasIntCols && intCol.colIx >= 0 {
+			return strconv.Itoa(intCol.colIx + 1), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,77 @@
+package csv_to_gorm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testSource struct {
+	ID       uint
+	Name     string  `xtg:"col:Name"`
+	Year     int     `xtg:"intcols:colname"`
+	Yield    float64 `xtg:"intcols:value"`
+	Row      int     `xtg:"meta:rownum"`
+	File     string  `xtg:"meta:filename"`
+	Sheet    string  `xtg:"meta:sheet"`
+	ImportID string  `xtg:"meta:importid"`
+	Col      int     `xtg:"meta:sourcecol"`
+}
+
+func TestMetaFields(t *testing.T) {
+	file := tempCSV(t, "Name;2020;2021\nCox;1.5;2.5\nGala;3;4\n")
+	got, err := CsvToSlice(file, ';', &testSource{}, Params{Sheet: "Yields", ImportID: "run-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := file.Name()
+	want := []testSource{
+		{Name: "Cox", Year: 2020, Yield: 1.5, Row: 2, File: name, Sheet: "Yields", ImportID: "run-1", Col: 2},
+		{Name: "Cox", Year: 2021, Yield: 2.5, Row: 2, File: name, Sheet: "Yields", ImportID: "run-1", Col: 3},
+		{Name: "Gala", Year: 2020, Yield: 3, Row: 3, File: name, Sheet: "Yields", ImportID: "run-1", Col: 2},
+		{Name: "Gala", Year: 2021, Yield: 4, Row: 3, File: name, Sheet: "Yields", ImportID: "run-1", Col: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v,\nwant %+v", got, want)
+	}
+
+	// each read of the file without an ImportID is given one of its own
+	first, _ := CsvToSlice(file, ';', &testSource{}, Params{})
+	second, _ := CsvToSlice(file, ';', &testSource{}, Params{})
+	firstIDs, secondIDs := first.([]testSource), second.([]testSource)
+	if len(firstIDs[0].ImportID) != 32 || firstIDs[0].ImportID != firstIDs[3].ImportID || firstIDs[0].ImportID == secondIDs[0].ImportID {
+		t.Errorf("import IDs %q and %q", firstIDs[0].ImportID, secondIDs[0].ImportID)
+	}
+}
+
+// the import log records each import, and the records point back to their entry
+func TestLogImport(t *testing.T) {
+	db := testDB(t)
+	file := tempCSV(t, "Name;2020;2021\nCox;1.5;2.5\nGala;3;lots\n")
+	result, err := Import(db, file, ';', &testSource{}, ImportParams{Migrate: true, LogImport: true, Params: Params{ErrorOnNaN: true}})
+	checkErrs(t, err, []string{"row 3: field Yield"})
+	if want := (ImportResult{RowsRead: 2, Inserted: 2, Rejected: 1}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	var entries []ImportLog
+	if err := db.Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d import log entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	hash, _ := hashFile(file)
+	if entry.TableName != "test_sources" || filepath.Base(entry.FileName) != "test.csv" || entry.FileHash != hash ||
+		entry.RowsRead != 2 || entry.Inserted != 2 || entry.Rejected != 1 || entry.Outcome != OutcomePartial ||
+		entry.FinishedAt == nil || entry.Error == "" {
+		t.Errorf("logged %+v", entry)
+	}
+
+	var ids []string
+	db.Model(&testSource{}).Distinct().Pluck("import_id", &ids)
+	if !reflect.DeepEqual(ids, []string{entry.ImportID}) {
+		t.Errorf("records have import IDs %v, want %s", ids, entry.ImportID)
+	}
+}
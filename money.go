@@ -0,0 +1,150 @@
+package csv_to_gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Money is an amount in a currency, read from cells such as €1.234,50, USD 12.00, 12.00 EUR or -£5.
+// Currency is the ISO 4217 code, given or worked out from the symbol, and is empty if the cell has neither.
+// It is stored as text such as "1234.50 EUR".  For separate amount and currency columns, tag the field
+// with gorm:"embedded;embeddedPrefix:price_"
+type Money struct {
+	Amount   Decimal
+	Currency string
+}
+
+var moneyType = reflect.TypeOf(Money{})
+
+var (
+	currencySymbolsMu sync.RWMutex
+	currencySymbols   = map[string]string{
+		"€": "EUR", "$": "USD", "US$": "USD", "£": "GBP", "¥": "JPY", "₹": "INR", "₩": "KRW", "₽": "RUB",
+		"₺": "TRY", "R$": "BRL", "zł": "PLN", "CHF": "CHF",
+	}
+)
+
+// RegisterCurrencySymbol makes Money cells with the symbol, before or after the amount, be in the currency
+func RegisterCurrencySymbol(symbol string, currency string) {
+	currencySymbolsMu.Lock()
+	defer currencySymbolsMu.Unlock()
+	currencySymbols[symbol] = currency
+}
+
+var (
+	currencyPrefix = regexp.MustCompile(`^([A-Za-z]{3})([^A-Za-z].*)$`)
+	currencySuffix = regexp.MustCompile(`^(.*[^A-Za-z])([A-Za-z]{3})$`)
+)
+
+// splitCurrency takes the currency symbol or code off the front or back of the text
+func splitCurrency(text string) (currency string, amount string) {
+	if currency, amount, ok := splitSymbol(text); ok {
+		return currency, amount
+	}
+	if match := currencyPrefix.FindStringSubmatch(text); match != nil {
+		return strings.ToUpper(match[1]), match[2]
+	}
+	if match := currencySuffix.FindStringSubmatch(text); match != nil {
+		return strings.ToUpper(match[2]), match[1]
+	}
+	return "", text
+}
+
+// splitSymbol takes a registered currency symbol off the front or back of the text, the longest first
+// so that US$ is not taken as $
+func splitSymbol(text string) (currency string, amount string, ok bool) {
+	currencySymbolsMu.RLock()
+	defer currencySymbolsMu.RUnlock()
+	symbols := make([]string, 0, len(currencySymbols))
+	for symbol := range currencySymbols {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return len(symbols[i]) > len(symbols[j]) })
+	for _, symbol := range symbols {
+		if strings.HasPrefix(text, symbol) {
+			return currencySymbols[symbol], strings.TrimPrefix(text, symbol), true
+		}
+		if strings.HasSuffix(text, symbol) {
+			return currencySymbols[symbol], strings.TrimSuffix(text, symbol), true
+		}
+	}
+	return "", text, false
+}
+
+// ParseMoney reads an amount with an optional currency symbol or ISO code before or after it.
+// The amount is read as ParseDecimal does
+func ParseMoney(s string) (Money, error) {
+	return ParseMoneySep(s, EitherSeparator)
+}
+
+// ParseMoneySep reads an amount of money with the decimal separator given
+func ParseMoneySep(s string, sep DecimalSeparator) (Money, error) {
+	text := strings.TrimSpace(s)
+	sign := ""
+	if strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		sign, text = text[:1], strings.TrimSpace(text[1:])
+	}
+	currency, amount := splitCurrency(text)
+	decimal, err := ParseDecimalSep(sign+strings.TrimSpace(amount), sep)
+	if err != nil {
+		return Money{}, fmt.Errorf("%q is not an amount of money: %w", s, err)
+	}
+	return Money{Amount: decimal, Currency: currency}, nil
+}
+
+// String gives the amount followed by the currency, if any, e.g. 1234.50 EUR
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Amount.String()
+	}
+	return m.Amount.String() + " " + m.Currency
+}
+
+// UnmarshalText lets JSON and YAML read Money, with a decimal point as String gives it
+func (m *Money) UnmarshalText(text []byte) error {
+	parsed, err := ParseMoneySep(string(text), DecimalPoint)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// MarshalText writes the Money as String does
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Value stores the Money as text, as String gives it
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads Money from the database.  NULL is read as 0 in no currency; use a *Money field to keep it
+func (m *Money) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case string:
+		return m.UnmarshalText([]byte(src))
+	case []byte:
+		return m.UnmarshalText(src)
+	case int64:
+		return m.UnmarshalText([]byte(strconv.FormatInt(src, 10)))
+	case float64:
+		return m.UnmarshalText([]byte(strconv.FormatFloat(src, 'f', -1, 64)))
+	}
+	return fmt.Errorf("cannot scan %T into Money", src)
+}
+
+// GormDataType makes gorm migrate Money fields to text columns
+func (Money) GormDataType() string {
+	return "string"
+}
@@ -0,0 +1,292 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// rowResult is what was made of one row of the file
+type rowResult struct {
+	seq      int // order in which the row was read, starting at 0
+	rowNo    int // row number in the file, starting at 1
+	records  []reflect.Value
+	skipped  int // records left out on purpose
+	filtered int // the row, or records of it, left out by the RowFilter or RecordFilter
+	errs     []error
+	binding  bool // errs are problems binding the model to the columns, which affect every row, rather than a row of data
+}
+
+// readRows reads the file and passes the records built from each row to emit.
+// With params.Workers above 1, the records are built by that many goroutines and, unless params.Unordered,
+// put back into the order of the file before being emitted.  emit is only ever called from the calling goroutine.
+// Cells which fail to convert are passed to emit in the errs of their row.  Only problems which stop the whole
+// file being read, an error from emit or the cancellation of ctx are returned.
+// If tee is not nil, the content of the file is written to it as it is read, e.g. to work out its hash
+func readRows(ctx context.Context, file *os.File, colSep rune, modelTyp reflect.Type, params Params, tee io.Writer, emit func(rowResult) error) error {
+	// make sure we start at the start of the file
+	file.Seek(0, 0)
+
+	// constants and columns from a mapping file add to those in the params
+	params = params.withMapping()
+
+	// the tags are parsed once per model type, not for every cell
+	plan, err := compilePlan(modelTyp, params.Mapping)
+	if err != nil {
+		return err
+	}
+
+	log := params.logger()
+	progress, counted := newProgressReporter(file, params, tee)
+	r := csv.NewReader(counted)
+	r.Comma = colSep
+
+	// Get headings from first row, which is needed before any record can be built
+	csvRecord, err := r.Read()
+	if err == io.EOF {
+		log.Debug("reached end of input file", "rows", 0)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("row 1: %w", err)
+	}
+	var fp *filePlan
+	var bindErrs error
+	if !params.FirstRowHasData {
+		fp, bindErrs = plan.bind(csvRecord, len(csvRecord), params)
+	} else {
+		fp, bindErrs = plan.bind(nil, len(csvRecord), params)
+	}
+	if fp == nil {
+		return bindErrs
+	}
+	hasLookup, hasMeta := false, false
+	fp.walk(func(fp *filePlan) {
+		fp.fileName = file.Name()
+		hasLookup = hasLookup || fp.hasLookup
+		hasMeta = hasMeta || fp.hasMeta
+	})
+	if hasLookup && params.Lookups == nil {
+		return errors.New("the lookup: fields of " + modelTyp.Name() + " need the Lookups of the params to be set")
+	}
+	if hasMeta && params.ImportID == "" {
+		params.ImportID = newImportID()
+	}
+	if bindErrs != nil {
+		if err := emit(rowResult{rowNo: 1, errs: bindErrs.(rowErrors), binding: true}); err != nil {
+			return err
+		}
+	}
+
+	// progress is counted as rows are emitted, so the callback is only called from this goroutine
+	if !params.FirstRowHasData {
+		progress.RowsRead = 1
+	}
+	emitRow := emit
+	emit = func(res rowResult) error {
+		if err := emitRow(res); err != nil {
+			return err
+		}
+		if len(res.errs) > 0 {
+			// the row is rejected, so its records are not used
+			progress.row(0)
+		} else {
+			progress.row(len(res.records))
+		}
+		return nil
+	}
+
+	// headings for the RowFilter
+	var headingCols map[string]int
+	if !params.FirstRowHasData {
+		headingCols = mapHeadingToCol(csvRecord)
+	}
+
+	// builds the records of a row
+	process := func(seq int, rowNo int, csvRecord []string, readErr error) rowResult {
+		res := rowResult{seq: seq, rowNo: rowNo}
+		if readErr != nil {
+			res.errs = []error{fmt.Errorf("row %d: %w", rowNo, readErr)}
+			return res
+		}
+		if !params.keepRow(rowNo, csvRecord, headingCols) {
+			res.filtered = 1
+			return res
+		}
+		var errs []error
+		res.records, res.skipped, errs = fp.records(csvRecord, rowNo, params)
+		res.records, res.filtered = params.keepRecords(res.records)
+		for _, err := range errs {
+			res.errs = append(res.errs, fmt.Errorf("row %d: %w", rowNo, err))
+		}
+		return res
+	}
+
+	seq := 0
+	rowNo := 1
+	if params.FirstRowHasData {
+		if err := emit(process(seq, rowNo, csvRecord, nil)); err != nil {
+			return err
+		}
+		seq++
+	}
+
+	if params.Workers <= 1 {
+		// for each line of the CSV file
+		for {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("stopped after row %d: %w", rowNo, err)
+			}
+			csvRecord, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			rowNo++
+			if err != nil && !isParseError(err) {
+				// the file itself cannot be read, so reading on would give the same error
+				return fmt.Errorf("row %d: %w", rowNo, err)
+			}
+			if err := emit(process(seq, rowNo, csvRecord, err)); err != nil {
+				return err
+			}
+			seq++
+		}
+	} else if err := readRowsParallel(ctx, r, seq, rowNo, params, process, emit); err != nil {
+		return err
+	}
+	log.Debug("reached end of input file", "rows", progress.RowsRead, "records", progress.Records)
+	progress.done()
+	return nil
+}
+
+// readRowsParallel fans the rows still to be read from r out to params.Workers goroutines.
+// At most four rows per worker are in flight at once, which bounds the memory used while waiting for
+// a slow row when the output is ordered, and holds back reading when the records are not being used fast enough
+func readRowsParallel(ctx context.Context, r *csv.Reader, seq int, rowNo int, params Params,
+	process func(seq int, rowNo int, csvRecord []string, readErr error) rowResult, emit func(rowResult) error) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		seq       int
+		rowNo     int
+		csvRecord []string
+		err       error
+	}
+	jobs := make(chan job, params.Workers)
+	results := make(chan rowResult, params.Workers)
+	window := make(chan struct{}, params.Workers*4)
+
+	// seq and rowNo belong to the reader from here on
+	nextSeq := seq
+	lastRowNo := rowNo
+
+	// the reader.  readErr is only looked at once readerDone is closed
+	readerDone := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(readerDone)
+		defer close(jobs)
+		for {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			csvRecord, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			rowNo++
+			if err != nil && !isParseError(err) {
+				readErr = fmt.Errorf("row %d: %w", rowNo, err)
+				return
+			}
+			select {
+			case jobs <- job{seq: seq, rowNo: rowNo, csvRecord: csvRecord, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			seq++
+		}
+	}()
+
+	// the workers
+	var wg sync.WaitGroup
+	for i := 0; i < params.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := process(j.seq, j.rowNo, j.csvRecord, j.err)
+				select {
+				case results <- res:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// stop the reader and workers, waiting for them so that nothing touches the file once we return
+	stop := func(err error) error {
+		cancel()
+		for range results {
+		}
+		<-readerDone
+		return err
+	}
+
+	// nothing more is emitted once the context is done
+	emitRow := func(res rowResult) error {
+		<-window
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stopped after row %d: %w", lastRowNo, err)
+		}
+		lastRowNo = res.rowNo
+		return emit(res)
+	}
+
+	pending := make(map[int]rowResult)
+	for res := range results {
+		if params.Unordered {
+			if err := emitRow(res); err != nil {
+				return stop(err)
+			}
+			continue
+		}
+		pending[res.seq] = res
+		for {
+			next, ok := pending[nextSeq]
+			if !ok {
+				break
+			}
+			delete(pending, nextSeq)
+			nextSeq++
+			if err := emitRow(next); err != nil {
+				return stop(err)
+			}
+		}
+	}
+	<-readerDone
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("stopped after row %d: %w", lastRowNo, err)
+	}
+	return readErr
+}
+
+// isParseError tells whether a read error is down to a badly formed row, after which reading can go on
+func isParseError(err error) bool {
+	var parseErr *csv.ParseError
+	return errors.As(err, &parseErr)
+}
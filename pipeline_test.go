@@ -0,0 +1,84 @@
+package csv_to_gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// rows converted by several workers come out in the order of the file unless Unordered is set
+func TestReadRowsParallel(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Name;Diameter;Found;Organic\n")
+	var want []testFruit
+	for i := 0; i < 1000; i++ {
+		if i == 500 {
+			sb.WriteString("Bad;6;unknown;yes\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "Apple %d;%d.5;%d;%t\n", i, i%10, 1800+i, i%3 == 0)
+		want = append(want, testFruit{fmt.Sprintf("Apple %d", i), float64(i%10) + 0.5, 1800 + i, i%3 == 0})
+	}
+
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"one worker", Params{}},
+		{"ordered", Params{Workers: 4}},
+		{"unordered", Params{Workers: 4, Unordered: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CsvToSlice(tempCSV(t, sb.String()), ';', &testFruit{}, tt.params)
+			checkErrs(t, err, []string{"row 502: field Found"})
+			fruits := got.([]testFruit)
+			if tt.params.Unordered {
+				sort.Slice(fruits, func(i, j int) bool { return fruits[i].Found < fruits[j].Found })
+			}
+			if !reflect.DeepEqual(fruits, want) {
+				t.Errorf("got %d records, want %d in the order of the file", len(fruits), len(want))
+			}
+		})
+	}
+}
+
+// an error reading the file, other than a badly formed row, stops the reading and is returned
+func TestReadRowsReadError(t *testing.T) {
+	for _, workers := range []int{0, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			if _, err := w.WriteString("Name;Diameter;Found;Organic\nCox;6.5;1825;true\n"); err != nil {
+				t.Fatal(err)
+			}
+
+			rows := 0
+			err = readRows(context.Background(), r, ';', reflect.TypeOf(testFruit{}), Params{Workers: workers}, nil,
+				func(res rowResult) error {
+					rows++
+					if rows > 10 {
+						t.Fatal("reading went on after the file was closed")
+					}
+					// the rest of the file cannot be read
+					r.Close()
+					return nil
+				})
+			if !errors.Is(err, os.ErrClosed) {
+				t.Errorf("got error %v, want %v", err, os.ErrClosed)
+			}
+			checkErrs(t, err, []string{"row 3: "})
+			if rows != 1 {
+				t.Errorf("%d rows emitted, want 1", rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,548 @@
+package csv_to_gorm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// fieldRole says where the value of a field comes from
+type fieldRole int
+
+const (
+	roleNone fieldRole = iota
+	roleConst
+	roleCol
+	roleIntColsHead
+	roleIntColsValue
+	roleIntColsGroup
+	roleMeltHead
+	roleMeltValue
+	roleMeta
+	roleAssoc
+)
+
+// fieldPlan is everything about a field of a model which is known before a file is read
+type fieldPlan struct {
+	index      int // index of the field in the struct
+	field      reflect.StructField
+	tag        Tag
+	role       fieldRole
+	convert    Converter
+	pattern    *regexp.Regexp
+	lookup     *lookupSpec
+	transforms []transformStep
+	values     map[string]string // the value map of the tag or mapping
+}
+
+// modelPlan is the compiled form of the tags and mapping of a model.
+// It is built once per model type and reused, so tags are not parsed for every cell
+type modelPlan struct {
+	typ        reflect.Type
+	fields     []fieldPlan
+	hasIntCols bool
+	hasMelt    bool
+	hasMeta    bool
+	hasLookup  bool
+	hasAssoc   bool
+	ignore     []string
+	headCols   *headingParser // picks out the intcols columns.  nil for the non zero integers
+	meltGroups []*meltGroup   // named melt groups, in the order their fields appear
+	dropEmpty  bool           // leave out records whose intcols and melt value cells are all empty
+	// hasPlainMelt is true if the model has a melt:value field outside a named group, which melts the columns left over
+	hasPlainMelt bool
+	meltSplit    *meltSplit // splits melt headings into a member and the named group of the column
+
+}
+
+type planKey struct {
+	typ     reflect.Type
+	mapping string // content of the mapping, so that mappings loaded afresh for each file share a plan
+}
+
+// plans caches a *modelPlan per planKey
+var plans sync.Map
+
+// forgetPlans empties the cache of plans, so that converters, transforms and value maps registered since
+// a model was first used are found when it is next used
+func forgetPlans() {
+	plans.Range(func(key, _ interface{}) bool {
+		plans.Delete(key)
+		return true
+	})
+}
+
+// compilePlan returns the plan for the model type, building it the first time the type is seen with
+// a mapping of the same content
+func compilePlan(typ reflect.Type, mapping *Mapping) (*modelPlan, error) {
+	key := planKey{typ: typ}
+	if mapping != nil {
+		content, err := json.Marshal(mapping)
+		if err != nil {
+			return nil, errors.New("mapping for " + typ.Name() + ": " + err.Error())
+		}
+		key.mapping = string(content)
+	}
+	if plan, ok := plans.Load(key); ok {
+		return plan.(*modelPlan), nil
+	}
+
+	if err := mapping.checkFields(typ); err != nil {
+		return nil, err
+	}
+	plan := &modelPlan{typ: typ}
+	for fldIx := 0; fldIx < typ.NumField(); fldIx++ {
+		fld := typ.Field(fldIx)
+		tag, err := ResolveTag(fld, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse tag for :  "+typ.Name()+". %w", err)
+		}
+
+		fp := fieldPlan{index: fldIx, field: fld, tag: tag, convert: ConvertString, values: tag.Values}
+		switch {
+		case tag.IsAssoc:
+			fp.role = roleAssoc
+			plan.hasAssoc = true
+			if assocType(fld.Type) == nil {
+				return nil, errors.New("field " + fld.Name + ": assoc fields must be a struct, a pointer to a struct or a slice of structs")
+			}
+		case tag.Meta != "":
+			fp.role = roleMeta
+			plan.hasMeta = true
+		case tag.IsMapConst:
+			fp.role = roleConst
+		case tag.IsMeltHead:
+			fp.role = roleMeltHead
+		case tag.IsMeltValue:
+			fp.role = roleMeltValue
+		case tag.IsIntColsHead:
+			fp.role = roleIntColsHead
+		case tag.IsIntColsValue:
+			fp.role = roleIntColsValue
+		case tag.IntColsGroup != "":
+			fp.role = roleIntColsGroup
+		case tag.HasColanme:
+			fp.role = roleCol
+		}
+		if tag.IsIntColsHead || tag.IsIntColsValue || tag.IntColsGroup != "" {
+			plan.hasIntCols = true
+		}
+		if tag.HeadCols != "" {
+			if plan.headCols != nil && plan.headCols.spec != tag.HeadCols {
+				return nil, errors.New("field " + fld.Name + ": headcols:" + tag.HeadCols + " differs from headcols:" + plan.headCols.spec + " of another field")
+			}
+			plan.headCols, err = parseHeadCols(tag.HeadCols)
+			if err != nil {
+				return nil, errors.New("field " + fld.Name + ": " + err.Error())
+			}
+		}
+		if tag.MeltCols != "" && tag.MeltGroup == "" {
+			return nil, errors.New("field " + fld.Name + ": meltcols is only for fields of a named melt group, such as melt:cost:value")
+		}
+		if tag.IsMeltHead || tag.IsMeltValue {
+			plan.hasMelt = true
+			if tag.MeltGroup == "" {
+				plan.hasPlainMelt = plan.hasPlainMelt || tag.IsMeltValue
+			} else if err := plan.addMeltGroup(fld.Name, tag); err != nil {
+				return nil, err
+			}
+		}
+		if tag.MeltSplit != "" {
+			if plan.meltSplit != nil && plan.meltSplit.spec != tag.MeltSplit {
+				return nil, errors.New("field " + fld.Name + ": meltsplit:" + tag.MeltSplit + " differs from meltsplit:" + plan.meltSplit.spec + " of another field")
+			}
+			plan.meltSplit, err = parseMeltSplit(tag.MeltSplit)
+			if err != nil {
+				return nil, errors.New("field " + fld.Name + ": " + err.Error())
+			}
+		}
+		plan.ignore = append(plan.ignore, tag.Ignore...)
+		plan.dropEmpty = plan.dropEmpty || tag.DropEmpty
+
+		if tag.Converter != "" {
+			converter, ok := lookupConverter(tag.Converter)
+			if !ok {
+				return nil, errors.New("field " + fld.Name + ": no converter registered as " + tag.Converter)
+			}
+			fp.convert = converter
+		}
+		if tag.Lookup != "" {
+			spec, err := parseLookup(tag.Lookup)
+			if err != nil {
+				return nil, errors.New("field " + fld.Name + ": " + err.Error())
+			}
+			fp.lookup = &spec
+			plan.hasLookup = true
+		}
+		if tag.Transform != nil {
+			fp.transforms, err = compileTransforms(tag.Transform, mapping)
+			if err != nil {
+				return nil, errors.New("field " + fld.Name + ": " + err.Error())
+			}
+		}
+		if tag.Pattern != "" {
+			fp.pattern, err = regexp.Compile(tag.Pattern)
+			if err != nil {
+				return nil, errors.New("field " + fld.Name + ": pattern is not a valid regular expression: " + err.Error())
+			}
+		}
+		plan.fields = append(plan.fields, fp)
+	}
+	if mapping != nil {
+		plan.ignore = append(plan.ignore, mapping.Ignore...)
+		plan.dropEmpty = plan.dropEmpty || mapping.DropEmpty
+	}
+	if plan.meltSplit != nil && plan.meltGroups == nil {
+		return nil, errors.New("meltsplit of " + typ.Name() + " needs named melt groups, such as melt:cost:value, for the measures")
+	}
+	for _, mg := range plan.meltGroups {
+		if mg.member == nil && plan.meltSplit == nil {
+			return nil, errors.New("melt group " + mg.name + " of " + typ.Name() + " needs a meltcols: sub-tag to pick out its columns, or a meltsplit:")
+		}
+	}
+	for _, fp := range plan.fields {
+		if fp.role == roleIntColsGroup && (plan.headCols == nil || !plan.headCols.hasGroup(fp.tag.IntColsGroup)) {
+			return nil, errors.New("field " + fp.field.Name + ": intcols:" + fp.tag.IntColsGroup + " is not a group of the headcols: of " + typ.Name())
+		}
+	}
+
+	actual, _ := plans.LoadOrStore(key, plan)
+	return actual.(*modelPlan), nil
+}
+
+// addMeltGroup adds the named melt group of a field, taking its columns from the field's meltcols: sub-tag if it has one
+func (plan *modelPlan) addMeltGroup(fieldName string, tag Tag) error {
+	var mg *meltGroup
+	for _, g := range plan.meltGroups {
+		if g.name == tag.MeltGroup {
+			mg = g
+		}
+	}
+	if mg == nil {
+		mg = &meltGroup{name: tag.MeltGroup}
+		plan.meltGroups = append(plan.meltGroups, mg)
+	}
+	if tag.MeltCols == "" {
+		return nil
+	}
+	if mg.member != nil && mg.spec != tag.MeltCols {
+		return errors.New("field " + fieldName + ": meltcols:" + tag.MeltCols + " differs from meltcols:" + mg.spec + " of melt group " + mg.name)
+	}
+	parsed, err := parseMeltCols(tag.MeltGroup, tag.MeltCols)
+	if err != nil {
+		return errors.New("field " + fieldName + ": " + err.Error())
+	}
+	*mg = *parsed
+	return nil
+}
+
+// headingCol is a column picked out by its heading, such as an intcols or melt column
+type headingCol struct {
+	heading string
+	colIx   int               // column index starting at 0
+	groups  map[string]string // parts of the heading, for intcols:<group> fields
+	// groupCols holds, for the members of named melt groups, the column of each group by group name
+	groupCols map[string]int
+}
+
+// filePlan is a model plan bound to the columns of one file
+type filePlan struct {
+	*modelPlan
+	srcCols  []int  // per field, the index (starting at 0) of the column holding its value, or -1
+	skip     []bool // per field, whether the field can never be set, e.g. due to a missing constant
+	intCols  []headingCol
+	meltCols []headingCol
+	fileName string       // for meta:filename fields
+	usedCols map[int]bool // columns read by the fields, other than melt columns
+	assocs   []assocPlan  // the models of assoc fields, bound to the same file
+}
+
+// assocPlan is the plan of an assoc field's model, whose records are made from the same row as its owner
+type assocPlan struct {
+	index int // index of the field in the owner
+	*filePlan
+}
+
+// assocType returns the model of an assoc field, or nil if the field cannot hold records
+func assocType(typ reflect.Type) reflect.Type {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+// bind works out which column each field is read from.  headings is nil if the first row has data.
+// Problems which would affect every row are returned in errs rather than repeated for each record.
+// The models of assoc fields are bound too, and no model melts a column read by another
+func (plan *modelPlan) bind(headings []string, numCols int, params Params) (fp *filePlan, errs error) {
+	fp, errs = plan.bindClaimed(headings, numCols, params, nil, nil)
+	if fp == nil || !plan.hasAssoc {
+		return fp, errs
+	}
+	claimed := make(map[int]bool)
+	melts := false
+	fp.walk(func(fp *filePlan) {
+		for colIx := range fp.usedCols {
+			claimed[colIx] = true
+		}
+		melts = melts || fp.hasMelt
+	})
+	if !melts {
+		return fp, errs
+	}
+	return plan.bindClaimed(headings, numCols, params, claimed, nil)
+}
+
+// walk calls fn for the plan and the plans of its assoc fields
+func (fp *filePlan) walk(fn func(fp *filePlan)) {
+	fn(fp)
+	for _, a := range fp.assocs {
+		a.walk(fn)
+	}
+}
+
+// bindClaimed is bind, leaving the claimed columns out of melt columns.  owners are the models the
+// plan is an assoc of, to stop models which refer back to each other binding for ever
+func (plan *modelPlan) bindClaimed(headings []string, numCols int, params Params, claimed map[int]bool, owners []reflect.Type) (fp *filePlan, errs error) {
+	fp = &filePlan{
+		modelPlan: plan,
+		srcCols:   make([]int, len(plan.fields)),
+		skip:      make([]bool, len(plan.fields)),
+		usedCols:  make(map[int]bool),
+	}
+	headingCols := mapHeadingToCol(headings)
+	usedCols := fp.usedCols
+
+	for ix, f := range plan.fields {
+		fp.srcCols[ix] = -1
+
+		colNo := params.ColMap[f.field.Name]
+		if colNo == 0 {
+			colNo = f.tag.ColNo
+		}
+		if colNo > numCols {
+			return nil, errors.New("Column supplied in map is out of range for field " + f.field.Name + ": " + strconv.Itoa(colNo))
+		}
+		// if a parameter column maps to the field
+		if colNo > 0 {
+			fp.srcCols[ix] = colNo - 1
+			usedCols[colNo-1] = true
+			continue
+		}
+
+		switch f.role {
+		case roleConst:
+			// trying to convert empty strings to numbers will bomb!
+			if params.ConstMap[f.tag.ConstMapKey] == "" && f.field.Type.Kind() != reflect.String {
+				errs = appendErr(errs, errors.New("tag constant: "+f.tag.ConstMapKey+" missing for :  "+plan.typ.Name()+". "))
+				fp.skip[ix] = true
+			}
+		case roleCol:
+			colNo := f.tag.colNo(headingCols)
+			if colNo == 0 {
+				params.logger().Warn("could not find column header", "header", f.tag.Colname, "model", plan.typ.Name())
+				errs = appendErr(errs, errors.New("Could not find column header "+f.tag.Colname+" in: "+plan.typ.Name()+". "))
+				fp.skip[ix] = true
+				continue
+			}
+			fp.srcCols[ix] = colNo - 1
+			usedCols[colNo-1] = true
+		case roleAssoc:
+			a, assocErrs := plan.bindAssoc(f, headings, numCols, params, claimed, owners)
+			if a == nil {
+				return nil, assocErrs
+			}
+			if assocErrs != nil {
+				for _, err := range assocErrs.(rowErrors) {
+					errs = appendErr(errs, err)
+				}
+			}
+			fp.assocs = append(fp.assocs, assocPlan{index: ix, filePlan: a})
+		}
+	}
+
+	if plan.hasIntCols {
+		if plan.headCols != nil {
+			fp.intCols = plan.headCols.cols(headings)
+		} else {
+			fp.intCols = getIntCols(headings)
+		}
+		for _, intCol := range fp.intCols {
+			usedCols[intCol.colIx] = true
+		}
+	}
+	if plan.hasMelt {
+		notMelted := usedCols
+		if claimed != nil {
+			notMelted = make(map[int]bool)
+			for colIx := range usedCols {
+				notMelted[colIx] = true
+			}
+			for colIx := range claimed {
+				notMelted[colIx] = true
+			}
+		}
+		if plan.meltGroups != nil {
+			fp.meltCols = plan.getMeltGroupCols(headings, notMelted)
+		} else {
+			fp.meltCols = getMeltCols(headings, notMelted, plan.ignore)
+		}
+	}
+	return fp, errs
+}
+
+// bindAssoc binds the model of an assoc field.  Mappings and the ColMap are for the outermost model only
+func (plan *modelPlan) bindAssoc(f fieldPlan, headings []string, numCols int, params Params, claimed map[int]bool, owners []reflect.Type) (*filePlan, error) {
+	owners = append(owners, plan.typ)
+	typ := assocType(f.field.Type)
+	for _, owner := range owners {
+		if owner == typ {
+			return nil, errors.New("field " + f.field.Name + ": assoc fields cannot lead back to " + typ.Name())
+		}
+	}
+	assocModel, err := compilePlan(typ, nil)
+	if err != nil {
+		return nil, err
+	}
+	params.ColMap = nil
+	return assocModel.bindClaimed(headings, numCols, params, claimed, owners)
+}
+
+// unpivoted returns the intcols and melt columns a row is spread over.  Models without intcols or melt
+// fields get a single empty entry, so that each row makes exactly one record
+func (fp *filePlan) unpivoted() (intCols []headingCol, meltCols []headingCol) {
+	intCols, meltCols = fp.intCols, fp.meltCols
+	if !fp.hasIntCols {
+		intCols = []headingCol{{colIx: -1}}
+	}
+	if !fp.hasMelt {
+		meltCols = []headingCol{{colIx: -1}}
+	}
+	return intCols, meltCols
+}
+
+// records builds the records for one row of the file, one for each intcols and melt column it is spread over
+// Records left out on purpose, e.g. by a lookup:...;skip field finding no parent, are counted in skipped
+func (fp *filePlan) records(csvRecord []string, rowNo int, params Params) (records []reflect.Value, skipped int, errs []error) {
+	intCols, meltCols := fp.unpivoted()
+	records = make([]reflect.Value, 0, len(intCols)*len(meltCols))
+	for _, intCol := range intCols {
+		for _, meltCol := range meltCols {
+			// create the new item to add to the database
+			record := reflect.New(fp.typ).Elem()
+			fillErrs, skip := fp.fill(record, csvRecord, rowNo, intCol, meltCol, params)
+			errs = append(errs, fillErrs...)
+			if skip {
+				skipped++
+				continue
+			}
+			for _, a := range fp.assocs {
+				assocErrs := a.fillAssoc(record.Field(fp.fields[a.index].index), csvRecord, rowNo, params)
+				for _, err := range assocErrs {
+					errs = append(errs, fmt.Errorf("%s: %w", fp.fields[a.index].field.Name, err))
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return records, skipped, errs
+}
+
+// fill sets the fields of a record from a row of the file, for one intcols and one melt column.
+// Cells which fail to convert leave the field unset and are reported in errs.  skip is true if the record
+// should be left out without an error
+func (fp *filePlan) fill(record reflect.Value, csvRecord []string, rowNo int, intCol headingCol, meltCol headingCol, params Params) (errs []error, skip bool) {
+	if fp.dropEmpty && fp.allValuesEmpty(csvRecord, intCol, meltCol, params) {
+		return nil, true
+	}
+	for ix := range fp.fields {
+		f := &fp.fields[ix]
+		if fp.skip[ix] {
+			continue
+		}
+
+		var cell string
+		switch {
+		case fp.srcCols[ix] >= 0:
+			if fp.srcCols[ix] >= len(csvRecord) {
+				errs = append(errs, errors.New("field "+f.field.Name+": row has only "+strconv.Itoa(len(csvRecord))+" columns"))
+				continue
+			}
+			cell = csvRecord[fp.srcCols[ix]]
+		case f.role == roleConst:
+			cell = params.ConstMap[f.tag.ConstMapKey]
+		case f.role == roleIntColsHead:
+			cell = intCol.heading
+		case f.role == roleIntColsGroup:
+			cell = intCol.groups[f.tag.IntColsGroup]
+		case f.role == roleMeltHead:
+			cell = meltCol.heading
+		case f.isValue():
+			var ok bool
+			cell, ok = f.valueCell(csvRecord, intCol, meltCol)
+			if !ok {
+				// the melt group has no column for the member, which leaves the field unset whatever the EmptyValues
+				continue
+			}
+			if params.isEmpty(cell) {
+				if params.EmptyValues == SkipEmpty {
+					// the record is left out, so the errors of its other cells do not matter
+					return nil, true
+				}
+				if params.EmptyValues == NullEmpty {
+					continue
+				}
+			}
+		case f.role == roleMeta:
+			var ok bool
+			cell, ok = fp.meta(f.tag.Meta, rowNo, intCol, meltCol, params)
+			if !ok {
+				continue
+			}
+		default:
+			continue
+		}
+
+		value, err := f.value(cell, params)
+		if errors.Is(err, errSkipRecord) {
+			return nil, true
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		record.Field(f.index).Set(value)
+	}
+	return errs, false
+}
+
+// fillAssoc sets an assoc field to the records made from the row by its model.
+// Struct and pointer fields take the first record, slices all of them
+func (a assocPlan) fillAssoc(field reflect.Value, csvRecord []string, rowNo int, params Params) []error {
+	records, _, errs := a.records(csvRecord, rowNo, params)
+	switch field.Kind() {
+	case reflect.Slice:
+		for _, record := range records {
+			field.Set(reflect.Append(field, record))
+		}
+	case reflect.Ptr:
+		if len(records) > 0 {
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().Set(records[0])
+			field.Set(ptr)
+		}
+	default:
+		if len(records) > 0 {
+			field.Set(records[0])
+		}
+	}
+	return errs
+}
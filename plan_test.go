@@ -0,0 +1,199 @@
+package csv_to_gorm
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mappings with the same content share a plan, so loading the mapping afresh for each file adds no plans
+func TestCompilePlanCache(t *testing.T) {
+	typ := reflect.TypeOf(testFruit{})
+	newMapping := func(col string) *Mapping {
+		mapping, err := ParseMapping([]byte(`{"fields": [{"name": "Name", "col": "`+col+`"}]}`), "json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mapping
+	}
+	countPlans := func() (count int) {
+		plans.Range(func(key, value interface{}) bool {
+			count++
+			return true
+		})
+		return count
+	}
+
+	first, err := compilePlan(typ, newMapping("Variety"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := countPlans()
+	for i := 0; i < 10; i++ {
+		plan, err := compilePlan(typ, newMapping("Variety"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan != first {
+			t.Fatal("a mapping with the same content was given a new plan")
+		}
+	}
+	if after := countPlans(); after != before {
+		t.Errorf("%d plans cached after reusing the mapping, want %d", after, before)
+	}
+
+	other, err := compilePlan(typ, newMapping("Cultivar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first || other.fields[0].tag.Colname != "Cultivar" {
+		t.Errorf("a mapping with other content was given the plan of the first")
+	}
+	plain, err := compilePlan(typ, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.fields[0].tag.Colname != "Name" {
+		t.Errorf("the plan without a mapping reads Name from %q", plain.fields[0].tag.Colname)
+	}
+}
+
+type benchRecord struct {
+	Name    string  `xtg:"col:Name"`
+	Country string  `xtg:"col:Country"`
+	Year    int     `xtg:"col:Year"`
+	Month   int     `xtg:"col:Month"`
+	Yield   float64 `xtg:"col:Yield"`
+	Area    float64 `xtg:"col:Area"`
+	Price   float64 `xtg:"col:Price"`
+	Organic bool    `xtg:"col:Organic"`
+	Grade   string  `xtg:"col:Grade"`
+	Count   uint    `xtg:"col:Count"`
+}
+
+func benchContent(rows int) string {
+	var sb strings.Builder
+	sb.WriteString("Name;Country;Year;Month;Yield;Area;Price;Organic;Grade;Count\n")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&sb, "Cox %d;UK;%d;%d;%d.5;12.25;0.99;%t;A;%d\n", i, 1990+i%30, 1+i%12, i%100, i%2 == 0, i)
+	}
+	return sb.String()
+}
+
+// readTagsPerCell reads the file as CsvToSlice did before plans were compiled, resolving the tag of every
+// field again for each record, as the baseline BenchmarkCsvToSlice compares plans with
+func readTagsPerCell(file *os.File, colSep rune, model interface{}, params Params) (interface{}, error) {
+	file.Seek(0, 0)
+	r := csv.NewReader(file)
+	r.Comma = colSep
+	headings, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	headingCols := mapHeadingToCol(headings)
+	modelTyp := reflect.TypeOf(model).Elem()
+	objSlice := reflect.Zero(reflect.SliceOf(modelTyp))
+	for {
+		csvRecord, err := r.Read()
+		if err == io.EOF {
+			return objSlice.Interface(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := reflect.New(modelTyp).Elem()
+		for fldIx := 0; fldIx < modelTyp.NumField(); fldIx++ {
+			fld := modelTyp.Field(fldIx)
+			tag, err := ResolveTag(fld, params.Mapping)
+			if err != nil {
+				return nil, err
+			}
+			if colNo := tag.colNo(headingCols); colNo > 0 {
+				value, err := ConvertString(csvRecord[colNo-1], fld.Type, params)
+				if err != nil {
+					return nil, err
+				}
+				record.Field(fldIx).Set(value)
+			}
+		}
+		objSlice = reflect.Append(objSlice, record)
+	}
+}
+
+// BenchmarkCsvToSlice reads a 10 field file with the plan cached across reads, with the cache emptied
+// before each read, so that the plan is compiled for every file, and as the baseline, with the tags
+// resolved for every cell as before plans were compiled
+func BenchmarkCsvToSlice(b *testing.B) {
+	for _, rows := range []int{10, 10000} {
+		for _, how := range []string{"cached", "uncached", "tags-per-cell"} {
+			name := fmt.Sprintf("rows=%d/%s", rows, how)
+			b.Run(name, func(b *testing.B) {
+				file := tempCSV(b, benchContent(rows))
+				b.ResetTimer()
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					var err error
+					switch how {
+					case "cached":
+						_, err = CsvToSlice(file, ';', &benchRecord{}, Params{})
+					case "uncached":
+						forgetPlans()
+						_, err = CsvToSlice(file, ';', &benchRecord{}, Params{})
+					default:
+						_, err = readTagsPerCell(file, ';', &benchRecord{}, Params{})
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(rows*b.N)/time.Since(start).Seconds(), "rows/s")
+			})
+		}
+	}
+}
+
+type testLate struct {
+	Name  string      `xtg:"col:Name,transform:testLate"`
+	Shade string      `xtg:"col:Shade,transform:map-values=testLate"`
+	Size  float64     `xtg:"col:Size,conv:testLate"`
+	Use   testLateUse `xtg:"col:Use"`
+}
+
+type testLateUse int
+
+func (u testLateUse) String() string { return [...]string{"Cooking", "Eating"}[u] }
+
+// converters, transforms, value maps and enums registered after a model is first used are used from then on
+func TestRegisterAfterUse(t *testing.T) {
+	content := "Name;Shade;Size;Use\nCox;r;6;Eating\n"
+	RegisterTransform("testLate", func(cell string, arg string) (string, error) { return strings.ToUpper(cell), nil })
+	RegisterValueMap("testLate", map[string]string{"r": "red"})
+	RegisterConverter("testLate", func(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+		return reflect.ValueOf(1.0), nil
+	})
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testLate{}, Params{})
+	checkErrs(t, err, []string{"row 2: field Use"})
+	if len(got.([]testLate)) != 0 {
+		t.Errorf("got %+v before the enum was registered", got)
+	}
+
+	RegisterTransform("testLate", func(cell string, arg string) (string, error) { return strings.ToLower(cell), nil })
+	RegisterValueMap("testLate", map[string]string{"r": "crimson"})
+	RegisterConverter("testLate", func(input string, outType reflect.Type, params Params) (reflect.Value, error) {
+		return reflect.ValueOf(2.0), nil
+	})
+	RegisterEnum(testLateUse(0), testLateUse(1))
+	got, err = CsvToSlice(tempCSV(t, content), ';', &testLate{}, Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testLate{{"cox", "crimson", 2, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
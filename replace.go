@@ -0,0 +1,68 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// constScope is the scope of a file whose model has mapConst fields: the records holding the same constants.
+// e.g. a yield file imported with the constant product=apple replaces the records with product apple
+func constScope(sch *schema.Schema, plan *modelPlan, params Params) (func(*gorm.DB) *gorm.DB, error) {
+	conds := make(map[string]interface{})
+	for ix := range plan.fields {
+		f := &plan.fields[ix]
+		if f.role != roleConst || params.ColMap[f.field.Name] > 0 || f.tag.ColNo > 0 {
+			continue
+		}
+		field := sch.LookUpField(f.field.Name)
+		if field == nil || field.DBName == "" {
+			continue
+		}
+		value, err := f.value(params.ConstMap[f.tag.ConstMapKey], params)
+		if err != nil {
+			return nil, err
+		}
+		conds[field.DBName] = value.Interface()
+	}
+	if len(conds) == 0 {
+		return nil, errors.New("import mode replace needs a Scope, or mapConst fields in " + sch.Name + " to make one from")
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(conds)
+	}, nil
+}
+
+// replaceScope deletes the records of model in scope then calls insert, all in one transaction.
+// Any error from insert, including rows rejected for cells which could not be converted, rolls back the delete.
+// The records are deleted outright, even if the model has a gorm.DeletedAt field
+func replaceScope(db *gorm.DB, model interface{}, scope func(*gorm.DB) *gorm.DB, params ImportParams,
+	insert func(tx *gorm.DB, params ImportParams) (ImportResult, error)) (ImportResult, error) {
+
+	var result ImportResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Unscoped().Scopes(scope).Delete(zeroModel(model))
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+
+		params.Mode = InsertOnly
+		params.Migrate = false
+		var err error
+		result, err = insert(tx, params)
+		result.Deleted = deleted.RowsAffected
+		return err
+	})
+	if err != nil {
+		// nothing was kept
+		return ImportResult{Rejected: result.Rejected}, err
+	}
+	return result, nil
+}
+
+// zeroModel returns a pointer to a new value of the type model points to, so that deleting it adds no conditions
+func zeroModel(model interface{}) interface{} {
+	return reflect.New(reflect.ValueOf(model).Elem().Type()).Interface()
+}
@@ -0,0 +1,77 @@
+package csv_to_gorm
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type testCrop struct {
+	ID        uint
+	Product   string  `xtg:"mapConst:product"`
+	Name      string  `xtg:"col:Name"`
+	Yield     float64 `xtg:"col:Yield"`
+	DeletedAt gorm.DeletedAt
+}
+
+// without a Scope, the records with the same constants as the file are replaced, and deleted outright
+func TestReplaceConstScope(t *testing.T) {
+	db := testDB(t)
+	importCrops := func(product string, content string, mode ImportMode) ImportResult {
+		t.Helper()
+		params := ImportParams{Mode: mode, Migrate: true, Params: Params{ConstMap: map[string]string{"product": product}}}
+		result, err := Import(db, tempCSV(t, content), ';', &testCrop{}, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	importCrops("apple", "Name;Yield\nCox;1\nGala;2\n", InsertOnly)
+	importCrops("pear", "Name;Yield\nConference;3\n", InsertOnly)
+
+	result := importCrops("apple", "Name;Yield\nFuji;4\n", ReplaceScope)
+	want := ImportResult{RowsRead: 1, Inserted: 1, Deleted: 2}
+	if result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	var names []string
+	db.Model(&testCrop{}).Order("name").Pluck("name", &names)
+	if !reflect.DeepEqual(names, []string{"Conference", "Fuji"}) {
+		t.Errorf("table holds %v", names)
+	}
+	var all int64
+	db.Unscoped().Model(&testCrop{}).Count(&all)
+	if all != 2 {
+		t.Errorf("%d records left including soft deleted ones, want 2", all)
+	}
+}
+
+func TestReplaceScopeNeedsScope(t *testing.T) {
+	content := "Name;Diameter;Found;Organic\nCox;6.5;1825;true\n"
+	db := testDB(t)
+	_, err := Import(db, tempCSV(t, content), ';', &testFruit{}, ImportParams{Mode: ReplaceScope, Migrate: true})
+	checkErrs(t, err, []string{"import mode replace needs a Scope"})
+	_, err = ImportTable(db, "fruits", tempCSV(t, content), ';', ImportParams{Mode: ReplaceScope, Migrate: true})
+	checkErrs(t, err, []string{"import mode replace needs the Scope of table fruits"})
+}
+
+func TestImportTableReplaceScope(t *testing.T) {
+	db := testDB(t)
+	if _, err := ImportTable(db, "crops", tempCSV(t, "Product;Name\napple;Cox\npear;Conference\n"), ';', ImportParams{Migrate: true}); err != nil {
+		t.Fatal(err)
+	}
+	params := ImportParams{Mode: ReplaceScope, Scope: func(db *gorm.DB) *gorm.DB { return db.Where("product = ?", "apple") }}
+	result, err := ImportTable(db, "crops", tempCSV(t, "Product;Name\napple;Gala\napple;Fuji\n"), ';', params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 2, Inserted: 2, Deleted: 1}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	var names []string
+	db.Table("crops").Order("name").Pluck("name", &names)
+	if !reflect.DeepEqual(names, []string{"Conference", "Fuji", "Gala"}) {
+		t.Errorf("table holds %v", names)
+	}
+}
@@ -0,0 +1,157 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Transform changes the text of a cell before it is checked and converted.  arg is what follows the = of the
+// step in the transform: sub-tag, e.g. " cm" for strip-suffix= cm, and is empty if there is none.
+// Transforms are registered by name with RegisterTransform
+type Transform func(cell string, arg string) (string, error)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]Transform{
+		"trim": func(cell string, arg string) (string, error) {
+			if arg == "" {
+				return strings.TrimSpace(cell), nil
+			}
+			return strings.Trim(cell, arg), nil
+		},
+		"lower": func(cell string, arg string) (string, error) {
+			return strings.ToLower(cell), nil
+		},
+		"upper": func(cell string, arg string) (string, error) {
+			return strings.ToUpper(cell), nil
+		},
+		"strip-suffix": func(cell string, arg string) (string, error) {
+			return strings.TrimSuffix(cell, arg), nil
+		},
+		"strip-prefix": func(cell string, arg string) (string, error) {
+			return strings.TrimPrefix(cell, arg), nil
+		},
+	}
+)
+
+// RegisterTransform makes a transform available to the transform: tag and the transform attribute of mapping files
+func RegisterTransform(name string, transform Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = transform
+	forgetPlans()
+}
+
+func lookupTransform(name string) (Transform, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	transform, ok := transforms[name]
+	return transform, ok
+}
+
+var (
+	valueMapsMu sync.RWMutex
+	valueMaps   = make(map[string]map[string]string)
+)
+
+// RegisterValueMap makes a table of replacement values available to the map-values transform, as
+// map-values=<name>.  Cells not in the table are left as they are
+func RegisterValueMap(name string, values map[string]string) {
+	valueMapsMu.Lock()
+	defer valueMapsMu.Unlock()
+	valueMaps[name] = values
+	forgetPlans()
+}
+
+func lookupValueMap(name string) (map[string]string, bool) {
+	valueMapsMu.RLock()
+	defer valueMapsMu.RUnlock()
+	values, ok := valueMaps[name]
+	return values, ok
+}
+
+// transformStep is one step of a field's transform chain
+type transformStep struct {
+	name      string
+	transform func(cell string) (string, error)
+}
+
+// compileTransforms builds the transform chain of a field.  Each step is <name> or <name>=<arg>.
+// The value maps of the mapping, if any, are looked in before those registered
+func compileTransforms(steps []string, mapping *Mapping) ([]transformStep, error) {
+	var chain []transformStep
+	for _, step := range steps {
+		name, arg := step, ""
+		if ix := strings.Index(step, "="); ix >= 0 {
+			name, arg = step[:ix], step[ix+1:]
+		}
+		ts := transformStep{name: name}
+		switch name {
+		case "replace":
+			// replace=<old>-><new>
+			ix := strings.Index(arg, "->")
+			if ix < 1 {
+				return nil, errors.New("transform " + step + " should be in the form replace=<old>-><new>")
+			}
+			from, to := arg[:ix], arg[ix+2:]
+			ts.transform = func(cell string) (string, error) {
+				return strings.ReplaceAll(cell, from, to), nil
+			}
+		case "regex-extract":
+			// the first group of the regular expression, or the whole match if it has none
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, errors.New("transform " + step + " is not a valid regular expression: " + err.Error())
+			}
+			ts.transform = func(cell string) (string, error) {
+				match := re.FindStringSubmatch(cell)
+				if match == nil {
+					return cell, fmt.Errorf("%q does not match %s", cell, arg)
+				}
+				if len(match) > 1 {
+					return match[1], nil
+				}
+				return match[0], nil
+			}
+		case "map-values":
+			values, ok := mapping.valueMap(arg)
+			if !ok {
+				values, ok = lookupValueMap(arg)
+			}
+			if !ok {
+				return nil, errors.New("transform " + step + ": no value map named " + arg)
+			}
+			ts.transform = func(cell string) (string, error) {
+				if value, ok := values[cell]; ok {
+					return value, nil
+				}
+				return cell, nil
+			}
+		default:
+			transform, ok := lookupTransform(name)
+			if !ok {
+				return nil, errors.New("no transform registered as " + name)
+			}
+			ts.transform = func(cell string) (string, error) {
+				return transform(cell, arg)
+			}
+		}
+		chain = append(chain, ts)
+	}
+	return chain, nil
+}
+
+// transform passes the cell through the field's transform chain
+func (f *fieldPlan) transform(cell string) (string, error) {
+	for _, step := range f.transforms {
+		var err error
+		cell, err = step.transform(cell)
+		if err != nil {
+			return cell, fmt.Errorf("transform %s: %w", step.name, err)
+		}
+	}
+	return cell, nil
+}
@@ -0,0 +1,97 @@
+package csv_to_gorm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileTransforms(t *testing.T) {
+	RegisterValueMap("testColours", map[string]string{"rot": "red", "grün": "green"})
+	mapping := &Mapping{ValueMaps: map[string]map[string]string{"testColours": {"rot": "crimson"}}}
+	tests := []struct {
+		steps   []string
+		mapping *Mapping
+		cell    string
+		want    string
+		err     string // part of the error expected, empty if none
+	}{
+		{steps: []string{"trim"}, cell: "  Cox ", want: "Cox"},
+		{steps: []string{"trim=*"}, cell: "**Cox*", want: "Cox"},
+		{steps: []string{"trim", "upper"}, cell: " Cox ", want: "COX"},
+		{steps: []string{"lower"}, cell: "Cox", want: "cox"},
+		{steps: []string{"strip-suffix= cm"}, cell: "9.8 cm", want: "9.8"},
+		{steps: []string{"strip-prefix=£"}, cell: "£12", want: "12"},
+		{steps: []string{"replace=,->."}, cell: "9,8", want: "9.8"},
+		{steps: []string{"replace=->x"}, err: "should be in the form replace=<old>-><new>"},
+		{steps: []string{`regex-extract=(\d+)`}, cell: "No. 42", want: "42"},
+		{steps: []string{`regex-extract=\d+ cm`}, cell: "about 12 cm", want: "12 cm"},
+		{steps: []string{`regex-extract=(\d+)`}, cell: "none", err: `"none" does not match`},
+		{steps: []string{"regex-extract=("}, err: "is not a valid regular expression"},
+		{steps: []string{"map-values=testColours"}, cell: "rot", want: "red"},
+		{steps: []string{"map-values=testColours"}, cell: "blau", want: "blau"},
+		{steps: []string{"map-values=testColours"}, mapping: mapping, cell: "rot", want: "crimson"},
+		{steps: []string{"map-values=testShapes"}, err: "no value map named testShapes"},
+		{steps: []string{"shout"}, err: "no transform registered as shout"},
+	}
+	for _, tt := range tests {
+		name := strings.Join(tt.steps, ";")
+		chain, err := compileTransforms(tt.steps, tt.mapping)
+		var got string
+		if err == nil {
+			f := fieldPlan{transforms: chain}
+			got, err = f.transform(tt.cell)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want one mentioning %q", name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if got != tt.want {
+			t.Errorf("%s turned %q into %q, want %q", name, tt.cell, got, tt.want)
+		}
+	}
+}
+
+type testMeasured struct {
+	Name   string  `xtg:"col:Name,transform:trim;upper"`
+	Height float64 `xtg:"col:Height,transform:strip-suffix= cm;testComma"`
+	Code   int     `xtg:"col:Code,transform:regex-extract=(\\d+)"`
+}
+
+// registered transforms are chained with the built in ones, and their errors reject the row
+func TestTransformTag(t *testing.T) {
+	RegisterTransform("testComma", func(cell string, arg string) (string, error) {
+		if strings.Count(cell, ",") > 1 {
+			return cell, errors.New("more than one comma")
+		}
+		return strings.Replace(cell, ",", ".", 1), nil
+	})
+	content := "Name;Height;Code\n cox ;9,8 cm;No. 12\nGala;1,2,3 cm;No. 13\nFuji;7 cm;none\n"
+	got, err := CsvToSlice(tempCSV(t, content), ';', &testMeasured{}, Params{})
+	checkErrs(t, err, []string{"row 3: ", "transform testComma: more than one comma", "row 4: ", "transform regex-extract"})
+	want := []testMeasured{{"COX", 9.8, 12}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// steps with commas go in a mapping file, which replaces the chain of the tag
+func TestTransformMapping(t *testing.T) {
+	mapping, err := ParseMapping([]byte("fields:\n  - name: Height\n    transform: [trim, \"replace=,->.\"]\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := CsvToSlice(tempCSV(t, "Name;Height;Code\nCox; 9,8 ;12\n"), ';', &testMeasured{}, Params{Mapping: mapping})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testMeasured{{"COX", 9.8, 12}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,380 @@
+package csv_to_gorm
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ImportMode says what Import does with a record whose key is already in the table
+type ImportMode int
+
+const (
+	InsertOnly   ImportMode = iota // every record is inserted.  A key already in the table fails the batch
+	Upsert                         // records already in the table are updated, the rest inserted
+	UpdateOnly                     // records already in the table are updated, the rest skipped
+	SkipExisting                   // records already in the table are left alone, the rest inserted
+	ReplaceScope                   // records in the scope of the file are deleted, then the file inserted, in one transaction
+)
+
+func (mode ImportMode) String() string {
+	switch mode {
+	case InsertOnly:
+		return "insert"
+	case Upsert:
+		return "upsert"
+	case UpdateOnly:
+		return "update"
+	case SkipExisting:
+		return "skip"
+	case ReplaceScope:
+		return "replace"
+	}
+	return fmt.Sprintf("ImportMode(%d)", int(mode))
+}
+
+// ParseImportMode reads the name of a mode as given by its String method
+func ParseImportMode(name string) (ImportMode, error) {
+	for _, mode := range []ImportMode{InsertOnly, Upsert, UpdateOnly, SkipExisting, ReplaceScope} {
+		if mode.String() == name {
+			return mode, nil
+		}
+	}
+	return InsertOnly, errors.New("unknown import mode " + name + ". should be one of insert, upsert, update, skip or replace")
+}
+
+// ImportResult counts what happened to the records of a file
+type ImportResult struct {
+	RowsRead    int64  // rows of data read from the file
+	Inserted    int64  // records inserted
+	Updated     int64  // records which replaced one already in the table
+	Skipped     int64  // records left out by the Mode, or by a lookup:...;skip field finding no parent
+	Deleted     int64  // records deleted by ReplaceScope
+	Rejected    int64  // rows left out as they had cells which could not be converted
+	Filtered    int64  // rows left out by the RowFilter and records left out by the RecordFilter
+	Associated  int64  // records of assoc fields inserted by ImportGraph
+	DuplicateOf string // ImportID of an earlier import of the same file, if the file was left out for that
+}
+
+// modelKeys works out the columns identifying a record of the model.  In order of preference these are
+// the Keys of the params, fields tagged key, the fields of the one unique index of the model, or a primary key
+// read from the file.  index is true if the columns are already known to have a unique index
+func modelKeys(sch *schema.Schema, plan *modelPlan, params ImportParams) (keys []string, index bool, err error) {
+	if len(params.Keys) > 0 {
+		for _, name := range params.Keys {
+			field := sch.LookUpField(name)
+			if field == nil {
+				return nil, false, errors.New("key " + name + " is not a field of " + sch.Name)
+			}
+			keys = append(keys, field.DBName)
+		}
+		return keys, false, nil
+	}
+
+	for _, f := range plan.fields {
+		if f.tag.IsKey {
+			field := sch.LookUpField(f.field.Name)
+			if field == nil || field.DBName == "" {
+				return nil, false, errors.New("key field " + f.field.Name + " is not stored by gorm")
+			}
+			keys = append(keys, field.DBName)
+		}
+	}
+	if len(keys) > 0 {
+		return keys, false, nil
+	}
+
+	var uniques [][]string
+	for _, field := range sch.Fields {
+		if field.Unique {
+			uniques = append(uniques, []string{field.DBName})
+		}
+	}
+	indexes := sch.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if indexes[name].Class != "UNIQUE" {
+			continue
+		}
+		var cols []string
+		for _, opt := range indexes[name].Fields {
+			cols = append(cols, opt.DBName)
+		}
+		uniques = append(uniques, cols)
+	}
+	if len(uniques) == 1 {
+		return uniques[0], true, nil
+	}
+	if len(uniques) > 1 {
+		return nil, false, errors.New(sch.Name + " has several unique indexes.  Tag the fields of the one to use with xtg key")
+	}
+
+	for _, f := range plan.fields {
+		if f.role == roleCol || f.tag.ColNo > 0 || params.ColMap[f.field.Name] > 0 {
+			if field := sch.LookUpField(f.field.Name); field != nil && field.PrimaryKey {
+				keys = append(keys, field.DBName)
+			}
+		}
+	}
+	if len(keys) > 0 {
+		return keys, true, nil
+	}
+	return nil, false, errors.New("import mode " + params.Mode.String() + " needs a key for " + sch.Name + ".  Tag the key fields with xtg key, give them a unique index or set Keys")
+}
+
+// createKeyIndex makes sure the key columns have the unique index ON CONFLICT needs
+func createKeyIndex(db *gorm.DB, table string, keys []string) error {
+	quoted := make([]string, len(keys))
+	for ix, key := range keys {
+		quoted[ix] = db.Statement.Quote(key)
+	}
+	name := "idx_" + table + "_" + strings.Join(keys, "_")
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + db.Statement.Quote(name) + " ON " +
+		db.Statement.Quote(table) + " (" + strings.Join(quoted, ",") + ")").Error
+}
+
+// batchWriter writes records to the database a batch at a time, in the way the Mode asks for
+type batchWriter struct {
+	db         *gorm.DB // a session with the model or table set
+	mode       ImportMode
+	keys       []string                              // db column names of the key
+	keyOf      func(row reflect.Value) []interface{} // values of the key of a record
+	updateCols []string                              // columns set when a record is updated.  All of them if nil
+	size       int
+
+	batch     reflect.Value
+	batchKeys map[string]bool
+	result    ImportResult
+}
+
+func newBatchWriter(db *gorm.DB, rowTyp reflect.Type, params ImportParams) *batchWriter {
+	return &batchWriter{
+		db:        db.Session(&gorm.Session{}),
+		mode:      params.Mode,
+		size:      params.batchSize(),
+		batch:     reflect.MakeSlice(reflect.SliceOf(rowTyp), 0, params.batchSize()),
+		batchKeys: make(map[string]bool),
+	}
+}
+
+// structKeyOf reads the key of a struct record
+func structKeyOf(sch *schema.Schema, keys []string) func(row reflect.Value) []interface{} {
+	fields := make([]*schema.Field, len(keys))
+	for ix, key := range keys {
+		fields[ix] = sch.LookUpField(key)
+	}
+	return func(row reflect.Value) []interface{} {
+		values := make([]interface{}, len(fields))
+		for ix, field := range fields {
+			value, _ := field.ValueOf(row)
+			values[ix] = keyValue(value)
+		}
+		return values
+	}
+}
+
+// mapKeyOf reads the key of a map record
+func mapKeyOf(keys []string) func(row reflect.Value) []interface{} {
+	return func(row reflect.Value) []interface{} {
+		values := make([]interface{}, len(keys))
+		for ix, key := range keys {
+			if value := row.MapIndex(reflect.ValueOf(key)); value.IsValid() {
+				values[ix] = value.Interface()
+			}
+		}
+		return values
+	}
+}
+
+func keyString(values []interface{}) string {
+	parts := make([]string, len(values))
+	for ix, value := range values {
+		parts[ix] = fmt.Sprint(keyValue(value))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// keyValue gives what a key field holds, rather than the address in a pointer field, and what the database
+// stores for a driver.Valuer such as sql.NullString.  A nil pointer is nil
+func keyValue(value interface{}) interface{} {
+	for {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil
+		}
+		if valuer, ok := value.(driver.Valuer); ok {
+			if stored, err := valuer.Value(); err == nil {
+				return stored
+			}
+		}
+		if rv.Kind() != reflect.Ptr {
+			return value
+		}
+		value = rv.Elem().Interface()
+	}
+}
+
+// add queues a record, writing the batch first if it is full or already holds a record with the same key,
+// so that a key repeated in the file updates the record written before it
+func (w *batchWriter) add(row reflect.Value) error {
+	if w.mode != InsertOnly {
+		key := keyString(w.keyOf(row))
+		if w.batchKeys[key] {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+		w.batchKeys[key] = true
+	}
+	w.batch = reflect.Append(w.batch, row)
+	if w.batch.Len() >= w.size {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the records queued so far
+func (w *batchWriter) flush() error {
+	if w.batch.Len() == 0 {
+		return nil
+	}
+	batch := w.batch
+	w.batch = reflect.MakeSlice(batch.Type(), 0, w.size)
+	w.batchKeys = make(map[string]bool)
+
+	if w.mode == InsertOnly {
+		affected, err := w.create(batch, nil)
+		w.result.Inserted += affected
+		return err
+	}
+
+	existing, err := w.existing(batch)
+	if err != nil {
+		return err
+	}
+	fresh := reflect.MakeSlice(batch.Type(), 0, batch.Len())
+	old := reflect.MakeSlice(batch.Type(), 0, batch.Len())
+	for ix := 0; ix < batch.Len(); ix++ {
+		if existing[keyString(w.keyOf(batch.Index(ix)))] {
+			old = reflect.Append(old, batch.Index(ix))
+		} else {
+			fresh = reflect.Append(fresh, batch.Index(ix))
+		}
+	}
+
+	switch w.mode {
+	case SkipExisting:
+		w.result.Skipped += int64(old.Len())
+		// a record written by someone else since the lookup is skipped rather than failing the batch
+		affected, err := w.create(fresh, &clause.OnConflict{Columns: w.conflictCols(), DoNothing: true})
+		w.result.Inserted += affected
+		w.result.Skipped += int64(fresh.Len()) - affected
+		return err
+	case UpdateOnly:
+		w.result.Skipped += int64(fresh.Len())
+		_, err := w.create(old, w.onConflictUpdate())
+		if err == nil {
+			w.result.Updated += int64(old.Len())
+		}
+		return err
+	case Upsert:
+		_, err := w.create(batch, w.onConflictUpdate())
+		if err == nil {
+			w.result.Inserted += int64(fresh.Len())
+			w.result.Updated += int64(old.Len())
+		}
+		return err
+	}
+	return errors.New("unknown import mode " + w.mode.String())
+}
+
+func (w *batchWriter) create(rows reflect.Value, onConflict *clause.OnConflict) (int64, error) {
+	if rows.Len() == 0 {
+		return 0, nil
+	}
+	rowsPtr := reflect.New(rows.Type())
+	rowsPtr.Elem().Set(rows)
+	tx := w.db
+	if onConflict != nil {
+		tx = tx.Clauses(*onConflict)
+	}
+	result := tx.Create(rowsPtr.Interface())
+	return result.RowsAffected, result.Error
+}
+
+func (w *batchWriter) conflictCols() []clause.Column {
+	cols := make([]clause.Column, len(w.keys))
+	for ix, key := range w.keys {
+		cols[ix] = clause.Column{Name: key}
+	}
+	return cols
+}
+
+func (w *batchWriter) onConflictUpdate() *clause.OnConflict {
+	if w.updateCols == nil {
+		return &clause.OnConflict{Columns: w.conflictCols(), UpdateAll: true}
+	}
+	return &clause.OnConflict{Columns: w.conflictCols(), DoUpdates: clause.AssignmentColumns(w.updateCols)}
+}
+
+// existingChunk is the most records whose composite keys are looked up in one query.  Each record adds
+// a level to the OR of the WHERE clause, and SQLite allows an expression tree only 1000 deep
+const existingChunk = 100
+
+// existing looks up which keys of the batch are already in the table
+func (w *batchWriter) existing(batch reflect.Value) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(w.keys) == 1 {
+		values := make([]interface{}, batch.Len())
+		for ix := range values {
+			values[ix] = w.keyOf(batch.Index(ix))[0]
+		}
+		if err := w.findKeys(batch.Type(), clause.IN{Column: clause.Column{Name: w.keys[0]}, Values: values}, existing); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	for start := 0; start < batch.Len(); start += existingChunk {
+		end := start + existingChunk
+		if end > batch.Len() {
+			end = batch.Len()
+		}
+		ors := make([]clause.Expression, 0, end-start)
+		for ix := start; ix < end; ix++ {
+			values := w.keyOf(batch.Index(ix))
+			ands := make([]clause.Expression, len(w.keys))
+			for keyIx, key := range w.keys {
+				ands[keyIx] = clause.Eq{Column: clause.Column{Name: key}, Value: values[keyIx]}
+			}
+			ors = append(ors, clause.And(ands...))
+		}
+		if err := w.findKeys(batch.Type(), clause.Or(ors...), existing); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// findKeys adds the keys of the records matching cond to existing
+func (w *batchWriter) findKeys(sliceTyp reflect.Type, cond clause.Expression, existing map[string]bool) error {
+	found := reflect.New(sliceTyp)
+	if err := w.db.Select(w.keys).Where(cond).Find(found.Interface()).Error; err != nil {
+		return err
+	}
+	for ix := 0; ix < found.Elem().Len(); ix++ {
+		existing[keyString(w.keyOf(found.Elem().Index(ix)))] = true
+	}
+	return nil
+}
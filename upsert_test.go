@@ -0,0 +1,253 @@
+package csv_to_gorm
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type testSale struct {
+	ID      uint
+	Name    string  `xtg:"col:Name,key"`
+	Product string  `xtg:"col:Product,key"`
+	Year    int     `xtg:"col:Year,key"`
+	Amount  float64 `xtg:"col:Amount"`
+}
+
+// testSales opens a database holding the sales of Cox and Gala in 2020
+func testSales(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testDB(t)
+	content := "Name;Product;Year;Amount\nCox;apple;2020;1\nGala;apple;2020;2\n"
+	if _, err := Import(db, tempCSV(t, content), ';', &testSale{}, ImportParams{Mode: Upsert, Migrate: true}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// salesAmounts gives the amount of each sale by name
+func salesAmounts(t *testing.T, db *gorm.DB) map[string]float64 {
+	t.Helper()
+	var sales []testSale
+	if err := db.Find(&sales).Error; err != nil {
+		t.Fatal(err)
+	}
+	amounts := make(map[string]float64, len(sales))
+	for _, sale := range sales {
+		amounts[sale.Name] = sale.Amount
+	}
+	return amounts
+}
+
+func TestImportModes(t *testing.T) {
+	content := "Name;Product;Year;Amount\nGala;apple;2020;20\nFuji;apple;2020;30\n"
+	tests := []struct {
+		name    string
+		params  ImportParams
+		want    ImportResult
+		amounts map[string]float64
+	}{
+		{
+			name:    "upsert",
+			params:  ImportParams{Mode: Upsert},
+			want:    ImportResult{RowsRead: 2, Inserted: 1, Updated: 1},
+			amounts: map[string]float64{"Cox": 1, "Gala": 20, "Fuji": 30},
+		},
+		{
+			name:    "update only",
+			params:  ImportParams{Mode: UpdateOnly},
+			want:    ImportResult{RowsRead: 2, Updated: 1, Skipped: 1},
+			amounts: map[string]float64{"Cox": 1, "Gala": 20},
+		},
+		{
+			name:    "skip existing",
+			params:  ImportParams{Mode: SkipExisting},
+			want:    ImportResult{RowsRead: 2, Inserted: 1, Skipped: 1},
+			amounts: map[string]float64{"Cox": 1, "Gala": 2, "Fuji": 30},
+		},
+		{
+			name: "replace scope",
+			params: ImportParams{Mode: ReplaceScope, Scope: func(db *gorm.DB) *gorm.DB {
+				return db.Where("year = ?", 2020)
+			}},
+			want:    ImportResult{RowsRead: 2, Inserted: 2, Deleted: 2},
+			amounts: map[string]float64{"Gala": 20, "Fuji": 30},
+		},
+		{
+			name:    "keys from the params",
+			params:  ImportParams{Mode: Upsert, Keys: []string{"Name", "Product", "Year"}},
+			want:    ImportResult{RowsRead: 2, Inserted: 1, Updated: 1},
+			amounts: map[string]float64{"Cox": 1, "Gala": 20, "Fuji": 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testSales(t)
+			result, err := Import(db, tempCSV(t, content), ';', &testSale{}, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.want {
+				t.Errorf("got %+v, want %+v", result, tt.want)
+			}
+			if amounts := salesAmounts(t, db); !reflect.DeepEqual(amounts, tt.amounts) {
+				t.Errorf("table holds %v, want %v", amounts, tt.amounts)
+			}
+		})
+	}
+}
+
+// a key repeated in the file updates the record written before it, even within a batch
+func TestImportRepeatedKey(t *testing.T) {
+	db := testSales(t)
+	content := "Name;Product;Year;Amount\nFuji;apple;2020;3\nFuji;apple;2020;4\n"
+	result, err := Import(db, tempCSV(t, content), ';', &testSale{}, ImportParams{Mode: Upsert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportResult{RowsRead: 2, Inserted: 1, Updated: 1}
+	if result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	if amounts := salesAmounts(t, db); amounts["Fuji"] != 4 {
+		t.Errorf("table holds %v", amounts)
+	}
+}
+
+// whole batches of the default size are looked up by a composite key, which SQLite once refused as
+// too deep an expression
+func TestImportCompositeKeyBatch(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("Name;Product;Year;Amount\n")
+	for i := 0; i < 1500; i++ {
+		fmt.Fprintf(&sb, "Seller %d;apple;%d;%d\n", i, 2000+i%20, i)
+	}
+	db := testDB(t)
+	params := ImportParams{Mode: Upsert, Migrate: true}
+	result, err := Import(db, tempCSV(t, sb.String()), ';', &testSale{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 1500, Inserted: 1500}); result != want {
+		t.Errorf("first import: got %+v, want %+v", result, want)
+	}
+
+	result, err = Import(db, tempCSV(t, sb.String()), ';', &testSale{}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{RowsRead: 1500, Updated: 1500}); result != want {
+		t.Errorf("second import: got %+v, want %+v", result, want)
+	}
+	var count int64
+	db.Model(&testSale{}).Count(&count)
+	if count != 1500 {
+		t.Errorf("table holds %d records, want 1500", count)
+	}
+}
+
+// a rejected row rolls back the whole of a ReplaceScope import, leaving the table as it was
+func TestReplaceScopeRollback(t *testing.T) {
+	db := testSales(t)
+	content := "Name;Product;Year;Amount\nGala;apple;2020;20\nFuji;apple;soon;30\n"
+	params := ImportParams{Mode: ReplaceScope, Scope: func(db *gorm.DB) *gorm.DB {
+		return db.Where("year = ?", 2020)
+	}}
+	result, err := Import(db, tempCSV(t, content), ';', &testSale{}, params)
+	checkErrs(t, err, []string{"row 3: field Year"})
+	if want := (ImportResult{Rejected: 1}); result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	want := map[string]float64{"Cox": 1, "Gala": 2}
+	if amounts := salesAmounts(t, db); !reflect.DeepEqual(amounts, want) {
+		t.Errorf("table holds %v, want %v", amounts, want)
+	}
+}
+
+type testLot struct {
+	ID     uint
+	Code   *string `xtg:"col:Code,key"`
+	Number *int    `xtg:"col:Number,key"`
+	Amount float64 `xtg:"col:Amount"`
+}
+
+// keys in pointer fields are matched by the values they point to
+func TestImportPointerKey(t *testing.T) {
+	db := testDB(t)
+	content := "Code;Number;Amount\nA;1;10\nB;2;20\n"
+	if _, err := Import(db, tempCSV(t, content), ';', &testLot{}, ImportParams{Mode: Upsert, Migrate: true}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		mode    ImportMode
+		content string
+		want    ImportResult
+		amounts map[string]float64
+	}{
+		{
+			name:    "upsert",
+			mode:    Upsert,
+			content: "Code;Number;Amount\nA;1;11\nC;3;30\n",
+			want:    ImportResult{RowsRead: 2, Inserted: 1, Updated: 1},
+			amounts: map[string]float64{"A": 11, "B": 20, "C": 30},
+		},
+		{
+			name:    "update only",
+			mode:    UpdateOnly,
+			content: "Code;Number;Amount\nB;2;21\nD;4;40\n",
+			want:    ImportResult{RowsRead: 2, Updated: 1, Skipped: 1},
+			amounts: map[string]float64{"A": 11, "B": 21, "C": 30},
+		},
+		{
+			name:    "repeated key",
+			mode:    Upsert,
+			content: "Code;Number;Amount\nE;5;50\nE;5;51\n",
+			want:    ImportResult{RowsRead: 2, Inserted: 1, Updated: 1},
+			amounts: map[string]float64{"A": 11, "B": 21, "C": 30, "E": 51},
+		},
+	}
+	for _, tt := range tests {
+		result, err := Import(db, tempCSV(t, tt.content), ';', &testLot{}, ImportParams{Mode: tt.mode})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, result, tt.want)
+		}
+		var lots []testLot
+		db.Find(&lots)
+		amounts := make(map[string]float64, len(lots))
+		for _, lot := range lots {
+			amounts[*lot.Code] = lot.Amount
+		}
+		if !reflect.DeepEqual(amounts, tt.amounts) {
+			t.Errorf("%s: table holds %v, want %v", tt.name, amounts, tt.amounts)
+		}
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	code, number := "A", 1
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{&code, "A"},
+		{&number, 1},
+		{(*int)(nil), nil},
+		{sql.NullString{String: "B", Valid: true}, "B"},
+		{sql.NullString{}, nil},
+		{&sql.NullInt64{Int64: 2, Valid: true}, int64(2)},
+		{"C", "C"},
+	}
+	for _, tt := range tests {
+		if got := keyValue(tt.value); got != tt.want {
+			t.Errorf("%#v gave %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}